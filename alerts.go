@@ -0,0 +1,306 @@
+package haproxy
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type AlertState string
+
+const (
+	AlertFiring   AlertState = "firing"
+	AlertResolved AlertState = "resolved"
+)
+
+// the metric counting the UP servers of a backend, besides the stat field names (see StatFieldNames)
+const AlertMetricUpServers = "up_servers"
+
+// a threshold on the sampled stats of the proxies or servers matching Type, Proxy and Server
+// (an empty Proxy or Server matches them all), eg: backend app error_ratio > 0.02 for 2m.
+//
+// The rule fires once the condition held for For, and resolves as soon as it no longer holds.
+type HaproxyAlertRule struct {
+	Name      string        `json:"name"`
+	Type      string        `json:"type"` // frontend, backend or server
+	Proxy     string        `json:"proxy,omitempty"`
+	Server    string        `json:"server,omitempty"`
+	Metric    string        `json:"metric"`
+	Operator  string        `json:"operator"` // >, >=, <, <=, == or !=
+	Threshold float64       `json:"threshold"`
+	For       time.Duration `json:"for,omitempty"`
+	Window    time.Duration `json:"window,omitempty"` // the window of the rates, the last two samples when zero
+}
+
+// fires when the 5xx responses of a backend exceed ratio (0.02 for 2%) between every sample for the given duration
+func ErrorRatioAbove(backend string, ratio float64, duration time.Duration) HaproxyAlertRule {
+	return HaproxyAlertRule{
+		Name:      backend + " error ratio",
+		Type:      "backend",
+		Proxy:     backend,
+		Metric:    "error_ratio",
+		Operator:  ">",
+		Threshold: ratio,
+		For:       duration,
+	}
+}
+
+// fires when fewer than count servers of a backend are UP for the given duration
+func UpServersBelow(backend string, count int, duration time.Duration) HaproxyAlertRule {
+	return HaproxyAlertRule{
+		Name:      backend + " up servers",
+		Type:      "backend",
+		Proxy:     backend,
+		Metric:    AlertMetricUpServers,
+		Operator:  "<",
+		Threshold: float64(count),
+		For:       duration,
+	}
+}
+
+func (r *HaproxyAlertRule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("alert rule without name")
+	}
+	if r.Type != "frontend" && r.Type != "backend" && r.Type != "server" {
+		return fmt.Errorf("alert rule %s: invalid type %q", r.Name, r.Type)
+	}
+	if r.Metric == AlertMetricUpServers {
+		if r.Type != "backend" {
+			return fmt.Errorf("alert rule %s: %s only applies to backends", r.Name, AlertMetricUpServers)
+		}
+	} else if _, exist := statFields[r.Metric]; !exist {
+		return fmt.Errorf("alert rule %s: unknown metric %q", r.Name, r.Metric)
+	}
+	if _, exist := alertOperators[r.Operator]; !exist {
+		return fmt.Errorf("alert rule %s: invalid operator %q", r.Name, r.Operator)
+	}
+	return nil
+}
+
+func (r *HaproxyAlertRule) matches(key HaproxyStatKey) bool {
+	return key.Type == r.Type && (r.Proxy == "" || r.Proxy == key.Proxy) && (r.Server == "" || r.Server == key.Server)
+}
+
+var alertOperators = map[string]func(value float64, threshold float64) bool{
+	">":  func(value float64, threshold float64) bool { return value > threshold },
+	">=": func(value float64, threshold float64) bool { return value >= threshold },
+	"<":  func(value float64, threshold float64) bool { return value < threshold },
+	"<=": func(value float64, threshold float64) bool { return value <= threshold },
+	"==": func(value float64, threshold float64) bool { return value == threshold },
+	"!=": func(value float64, threshold float64) bool { return value != threshold },
+}
+
+// a rule firing or resolving for a proxy or server
+type HaproxyAlertEvent struct {
+	Rule      string         `json:"rule"`
+	State     AlertState     `json:"state"`
+	Key       HaproxyStatKey `json:"key"`
+	Metric    string         `json:"metric"`
+	Value     float64        `json:"value"`
+	Operator  string         `json:"operator"`
+	Threshold float64        `json:"threshold"`
+	Since     time.Time      `json:"since"` // when the condition started to hold
+	Time      time.Time      `json:"time"`
+}
+
+func (e HaproxyAlertEvent) String() string {
+	return fmt.Sprintf("[%s] %s: %s %s = %s (%s %s) since %s", e.State, e.Rule, e.Key, e.Metric,
+		strconv.FormatFloat(e.Value, 'g', 4, 64), e.Operator, strconv.FormatFloat(e.Threshold, 'g', 4, 64), e.Since.Format(time.RFC3339))
+}
+
+// receives the alert events
+type HaproxyAlertNotifier interface {
+	Notify(event HaproxyAlertEvent) error
+}
+
+// logs the events, with the standard logger when Logger is nil
+type HaproxyLogNotifier struct {
+	Logger *log.Logger
+}
+
+func (n *HaproxyLogNotifier) Notify(event HaproxyAlertEvent) error {
+	if n.Logger == nil {
+		log.Println(event.String())
+		return nil
+	}
+	n.Logger.Println(event.String())
+	return nil
+}
+
+// posts the events as json to an url
+type HaproxyWebhookNotifier struct {
+	Url     string
+	Headers map[string]string
+	Rest    *resty.Client
+}
+
+func NewHaproxyWebhookNotifier(url string) *HaproxyWebhookNotifier {
+	return &HaproxyWebhookNotifier{Url: url, Rest: resty.New().SetTimeout(10 * time.Second)}
+}
+
+func (n *HaproxyWebhookNotifier) Notify(event HaproxyAlertEvent) error {
+	resp, err := n.Rest.R().
+		SetHeader("Content-Type", "application/json").
+		SetHeaders(n.Headers).
+		SetBody(event).
+		Post(n.Url)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("webhook notification failed: %s", resp.Status())
+	}
+	return nil
+}
+
+// evaluates the rules against the sampled stats and notifies the firing and resolved alerts
+type HaproxyAlertEngine struct {
+	Rules     []HaproxyAlertRule
+	Notifiers []HaproxyAlertNotifier
+	OnError   func(err error) // called with the notifier errors when not nil
+
+	lock   sync.Mutex
+	alerts map[alertId]*alertInstance
+}
+
+type alertId struct {
+	rule int
+	key  HaproxyStatKey
+}
+
+type alertInstance struct {
+	since  time.Time
+	firing bool
+}
+
+// create an engine, the rules are validated
+func NewHaproxyAlertEngine(rules []HaproxyAlertRule, notifiers ...HaproxyAlertNotifier) (*HaproxyAlertEngine, error) {
+	for i := range rules {
+		if err := rules[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &HaproxyAlertEngine{Rules: rules, Notifiers: notifiers, alerts: map[alertId]*alertInstance{}}, nil
+}
+
+// evaluate the rules after each sample of the sampler
+func (e *HaproxyAlertEngine) Watch(sampler *HaproxyStatsSampler) {
+	sampler.OnSample(func(sampler *HaproxyStatsSampler) {
+		e.Evaluate(sampler)
+	})
+}
+
+// evaluate the rules against the current samples, notify and return the events.
+// The alerts of the proxies and servers which are no longer sampled are resolved.
+func (e *HaproxyAlertEngine) Evaluate(sampler *HaproxyStatsSampler) []HaproxyAlertEvent {
+	e.lock.Lock()
+	now := time.Now()
+	events := []HaproxyAlertEvent{}
+	seen := map[alertId]bool{}
+	keys := sampler.Keys()
+	for i := range e.Rules {
+		rule := &e.Rules[i]
+		for _, key := range keys {
+			if !rule.matches(key) {
+				continue
+			}
+			// a key still sampled keeps its alert, and the For timer, while its value is unknown
+			id := alertId{rule: i, key: key}
+			seen[id] = true
+			value, ok := alertValue(sampler, keys, rule, key)
+			if !ok {
+				continue
+			}
+			alert, exist := e.alerts[id]
+			holds := alertOperators[rule.Operator](value, rule.Threshold)
+			switch {
+			case holds && !exist:
+				alert = &alertInstance{since: now}
+				e.alerts[id] = alert
+			case !holds && exist:
+				if alert.firing {
+					events = append(events, alertEvent(rule, key, AlertResolved, value, alert.since, now))
+				}
+				delete(e.alerts, id)
+				continue
+			case !holds:
+				continue
+			}
+			if !alert.firing && now.Sub(alert.since) >= rule.For {
+				alert.firing = true
+				events = append(events, alertEvent(rule, key, AlertFiring, value, alert.since, now))
+			}
+		}
+	}
+	for id, alert := range e.alerts {
+		if !seen[id] {
+			if alert.firing {
+				events = append(events, alertEvent(&e.Rules[id.rule], id.key, AlertResolved, 0, alert.since, now))
+			}
+			delete(e.alerts, id)
+		}
+	}
+	e.lock.Unlock()
+
+	for _, event := range events {
+		for _, notifier := range e.Notifiers {
+			if err := notifier.Notify(event); err != nil && e.OnError != nil {
+				e.OnError(err)
+			}
+		}
+	}
+	return events
+}
+
+// the alerts currently firing
+func (e *HaproxyAlertEngine) Firing() []HaproxyAlertEvent {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	firing := []HaproxyAlertEvent{}
+	for id, alert := range e.alerts {
+		if alert.firing {
+			firing = append(firing, HaproxyAlertEvent{Rule: e.Rules[id.rule].Name, State: AlertFiring, Key: id.key, Metric: e.Rules[id.rule].Metric, Since: alert.since})
+		}
+	}
+	return firing
+}
+
+func alertEvent(rule *HaproxyAlertRule, key HaproxyStatKey, state AlertState, value float64, since time.Time, now time.Time) HaproxyAlertEvent {
+	return HaproxyAlertEvent{
+		Rule:      rule.Name,
+		State:     state,
+		Key:       key,
+		Metric:    rule.Metric,
+		Value:     value,
+		Operator:  rule.Operator,
+		Threshold: rule.Threshold,
+		Since:     since,
+		Time:      now,
+	}
+}
+
+// the value of the rule metric for a proxy or server, false until enough samples are available
+func alertValue(sampler *HaproxyStatsSampler, keys []HaproxyStatKey, rule *HaproxyAlertRule, key HaproxyStatKey) (float64, bool) {
+	if rule.Metric == AlertMetricUpServers {
+		up := 0
+		for _, server := range keys {
+			if server.Type != "server" || server.Proxy != key.Proxy {
+				continue
+			}
+			if samples := sampler.Samples(server); len(samples) > 0 && IsServerUp(samples[len(samples)-1].Values.Status) {
+				up++
+			}
+		}
+		return float64(up), true
+	}
+	rates, ok := sampler.Rates(key, rule.Window)
+	if !ok {
+		return 0, false
+	}
+	return statFields[rule.Metric].value(&rates), true
+}
@@ -0,0 +1,119 @@
+package haproxy
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// a client returning the next stats on each GetStats call
+type fakeStatsSequence struct {
+	IHaproxyClient
+	stats []HaproxyStats
+}
+
+func (c *fakeStatsSequence) GetStats() (*HaproxyStats, error) {
+	stats := c.stats[0]
+	c.stats = c.stats[1:]
+	return &stats, nil
+}
+
+func TestAlertEvaluate(t *testing.T) {
+	// the backend app with its 2xx and 5xx responses and the status of its two servers
+	app := func(ok int, errors int, app1 string, app2 string) HaproxyStats {
+		return HaproxyStats{{Stats: []HaproxyNativeStat{
+			{Type: "backend", Name: "app", Stats: HaproxyNativeStatValues{Mode: "http", Hrsp2Xx: ok, Hrsp5Xx: errors}},
+			{Type: "server", BackendName: "app", Name: "app1", Stats: HaproxyNativeStatValues{Status: app1}},
+			{Type: "server", BackendName: "app", Name: "app2", Stats: HaproxyNativeStatValues{Status: app2}},
+		}}}
+	}
+	tests := []struct {
+		name   string
+		rule   HaproxyAlertRule
+		stats  []HaproxyStats
+		events []string // the events of each evaluation, "-" when there were none
+	}{
+		{
+			"server down then back",
+			UpServersBelow("app", 2, 0),
+			[]HaproxyStats{app(0, 0, "UP", "UP"), app(0, 0, "UP", "DOWN"), app(0, 0, "UP", "DOWN"), app(0, 0, "UP", "UP")},
+			[]string{"-", "firing 1", "-", "resolved 2"},
+		},
+		{
+			"rates need two samples",
+			ErrorRatioAbove("app", 0.1, 0),
+			[]HaproxyStats{app(0, 0, "UP", "UP"), app(50, 50, "UP", "UP"), app(150, 50, "UP", "UP")},
+			[]string{"-", "firing 0.5", "resolved 0"},
+		},
+		{
+			"not held for long enough",
+			ErrorRatioAbove("app", 0.1, time.Hour),
+			[]HaproxyStats{app(0, 0, "UP", "UP"), app(50, 50, "UP", "UP"), app(100, 100, "UP", "UP")},
+			[]string{"-", "-", "-"},
+		},
+		{
+			"resolved when the backend is gone",
+			UpServersBelow("app", 3, 0),
+			[]HaproxyStats{app(0, 0, "UP", "UP"), {}},
+			[]string{"firing 2", "resolved 0"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sampler := NewHaproxyStatsSampler(&fakeStatsSequence{stats: test.stats}, time.Second, 10)
+			engine, err := NewHaproxyAlertEngine([]HaproxyAlertRule{test.rule})
+			if err != nil {
+				t.Fatal(err)
+			}
+			events := []string{}
+			for range test.stats {
+				if err := sampler.SampleOnce(); err != nil {
+					t.Fatal(err)
+				}
+				step := "-"
+				for _, event := range engine.Evaluate(sampler) {
+					step = string(event.State) + " " + strconv.FormatFloat(event.Value, 'g', -1, 64)
+				}
+				events = append(events, step)
+			}
+			if !reflect.DeepEqual(events, test.events) {
+				t.Fatalf("events %q, want %q", events, test.events)
+			}
+		})
+	}
+}
+
+func TestAlertEvaluateUnknownValue(t *testing.T) {
+	app := func(ok int, errors int) HaproxyStats {
+		return HaproxyStats{{Stats: []HaproxyNativeStat{
+			{Type: "backend", Name: "app", Stats: HaproxyNativeStatValues{Mode: "http", Hrsp2Xx: ok, Hrsp5Xx: errors}},
+		}}}
+	}
+	engine, err := NewHaproxyAlertEngine([]HaproxyAlertRule{ErrorRatioAbove("app", 0.1, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sampler := NewHaproxyStatsSampler(&fakeStatsSequence{stats: []HaproxyStats{app(0, 0), app(50, 50)}}, time.Second, 10)
+	for i := 0; i < 2; i++ {
+		if err := sampler.SampleOnce(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if events := engine.Evaluate(sampler); len(events) != 1 || events[0].State != AlertFiring {
+		t.Fatalf("events %v, want the alert firing", events)
+	}
+	// a new sampler has a single sample of the backend, its error ratio is unknown until the next one
+	sampler = NewHaproxyStatsSampler(&fakeStatsSequence{stats: []HaproxyStats{app(50, 50), app(100, 100)}}, time.Second, 10)
+	for i := 0; i < 2; i++ {
+		if err := sampler.SampleOnce(); err != nil {
+			t.Fatal(err)
+		}
+		if events := engine.Evaluate(sampler); len(events) != 0 {
+			t.Fatalf("sample %d: events %v, want the alert still firing", i, events)
+		}
+	}
+	if firing := engine.Firing(); len(firing) != 1 {
+		t.Fatalf("firing %v, want the error ratio alert", firing)
+	}
+}
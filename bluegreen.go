@@ -0,0 +1,188 @@
+package haproxy
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// switches the traffic of a frontend between two backends (blue and green).
+//
+// Without Cond the default_backend of the frontend is switched, otherwise the backend switching rule
+// with that Cond and CondTest (eg: "if", "{ hdr(host) -i app.example.com }") targeting one of the two
+// backends is, and it is created at index 0 when missing.
+type HaproxyBlueGreen struct {
+	Client   IHaproxyClient
+	Frontend string
+	Blue     string
+	Green    string
+	Cond     string
+	CondTest string
+	// the servers which must be UP in the new backend, 1 when zero
+	MinUpServers int
+	// how long the health of the new backend is awaited, 30 seconds when zero
+	HealthTimeout time.Duration
+	// switch back to the previous backend when the new one isn't healthy in time
+	RollbackOnFailure bool
+}
+
+// the outcome of a switch
+type HaproxySwitchResult struct {
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	UpServers  []string `json:"up_servers"` // the UP servers of the new backend at the end of the health check
+	Healthy    bool     `json:"healthy"`
+	RolledBack bool     `json:"rolled_back"`
+}
+
+// the backend currently receiving the traffic, empty when it is neither blue nor green
+func (b *HaproxyBlueGreen) Active() (string, error) {
+	if b.Cond == "" {
+		frontend, err := b.frontend()
+		if err != nil {
+			return "", err
+		}
+		return b.color(frontend.DefaultBackend), nil
+	}
+	rule, err := b.rule()
+	if err != nil || rule == nil {
+		return "", err
+	}
+	return rule.Name, nil
+}
+
+// switch the traffic to the backend (blue or green), wait for the reload and the health of the backend
+func (b *HaproxyBlueGreen) SwitchTo(ctx context.Context, backend string) (*HaproxySwitchResult, error) {
+	if b.color(backend) == "" {
+		return nil, fmt.Errorf("backend %s is neither %s nor %s", backend, b.Blue, b.Green)
+	}
+	active, err := b.Active()
+	if err != nil {
+		return nil, err
+	}
+	result := HaproxySwitchResult{From: active, To: backend}
+	if active != backend {
+		reloadId, err := b.apply(backend)
+		if err != nil {
+			return &result, err
+		}
+		if err := WaitForReload(ctx, b.Client, reloadId, time.Second); err != nil {
+			return &result, err
+		}
+	}
+	result.UpServers, err = b.awaitHealth(ctx, backend)
+	result.Healthy = err == nil
+	if err == nil {
+		return &result, nil
+	}
+	if b.RollbackOnFailure && active != "" && active != backend {
+		reloadId, rollbackErr := b.apply(active)
+		if rollbackErr != nil {
+			return &result, fmt.Errorf("%w, switching back to %s failed: %v", err, active, rollbackErr)
+		}
+		result.RolledBack = true
+		if waitErr := WaitForReload(ctx, b.Client, reloadId, time.Second); waitErr != nil {
+			return &result, fmt.Errorf("%w, switched back to %s: %v", err, active, waitErr)
+		}
+	}
+	return &result, err
+}
+
+// switch the traffic to the other backend
+func (b *HaproxyBlueGreen) Switch(ctx context.Context) (*HaproxySwitchResult, error) {
+	active, err := b.Active()
+	if err != nil {
+		return nil, err
+	}
+	if active == b.Blue {
+		return b.SwitchTo(ctx, b.Green)
+	}
+	return b.SwitchTo(ctx, b.Blue)
+}
+
+func (b *HaproxyBlueGreen) color(backend string) string {
+	if backend != "" && (backend == b.Blue || backend == b.Green) {
+		return backend
+	}
+	return ""
+}
+
+// the whole frontend, so that replacing it changes nothing but the default backend
+func (b *HaproxyBlueGreen) frontend() (*HaproxyAddFrontend, error) {
+	frontend, err := b.Client.GetFrontend(b.Frontend)
+	if err != nil {
+		return nil, fmt.Errorf("frontend %s: %w", b.Frontend, err)
+	}
+	return &frontend.Data, nil
+}
+
+// the switching rule with the configured condition targeting blue or green, nil when missing
+func (b *HaproxyBlueGreen) rule() (*HaproxyAddBackendSwitchingRule, error) {
+	rules, err := b.Client.GetBackendSwitchingRules(b.Frontend)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rules.Data {
+		rule := rules.Data[i]
+		if rule.Cond == b.Cond && rule.CondTest == b.CondTest && b.color(rule.Name) != "" {
+			return &rule, nil
+		}
+	}
+	return nil, nil
+}
+
+// point the default backend or the switching rule to the backend in one transaction, returns the reload id
+func (b *HaproxyBlueGreen) apply(backend string) (string, error) {
+	if b.Cond == "" {
+		frontend, err := b.frontend()
+		if err != nil {
+			return "", err
+		}
+		frontend.DefaultBackend = backend
+		return RunTransactionReload(b.Client, func(client IHaproxyClient, transactionId string) error {
+			return client.ReplaceFrontend(b.Frontend, transactionId, frontend)
+		})
+	}
+	rule, err := b.rule()
+	if err != nil {
+		return "", err
+	}
+	return RunTransactionReload(b.Client, func(client IHaproxyClient, transactionId string) error {
+		if rule == nil {
+			return client.AddBackendSwitchingRule(b.Frontend, transactionId, &HaproxyAddBackendSwitchingRule{Cond: b.Cond, CondTest: b.CondTest, Index: 0, Name: backend})
+		}
+		rule.Name = backend
+		return client.ReplaceBackendSwitchingRule(b.Frontend, rule.Index, transactionId, rule)
+	})
+}
+
+// poll the stats until enough servers of the backend are UP
+func (b *HaproxyBlueGreen) awaitHealth(ctx context.Context, backend string) ([]string, error) {
+	minUp := b.MinUpServers
+	if minUp <= 0 {
+		minUp = 1
+	}
+	timeout := b.HealthTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		stats, err := b.Client.GetStats()
+		if err != nil {
+			return nil, err
+		}
+		up := stats.upServers(backend)
+		if len(up) >= minUp {
+			return up, nil
+		}
+		if !time.Now().Before(deadline) {
+			return up, fmt.Errorf("backend %s has %d servers UP after %s, %d required", backend, len(up), timeout, minUp)
+		}
+		select {
+		case <-ctx.Done():
+			return up, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
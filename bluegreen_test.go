@@ -0,0 +1,153 @@
+package haproxy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// a single frontend node applying the changes right away, the servers of upBackends are UP
+type fakeBlueGreenNode struct {
+	IHaproxyClient
+	frontend     HaproxyAddFrontend
+	rules        []HaproxyAddBackendSwitchingRule
+	upBackends   map[string]bool
+	failReloads  bool
+	reloads      int
+	transactions int
+}
+
+func (n *fakeBlueGreenNode) GetFrontend(name string) (*HaproxyFrontend, error) {
+	return &HaproxyFrontend{Data: n.frontend}, nil
+}
+
+func (n *fakeBlueGreenNode) ReplaceFrontend(name string, transactionId string, frontend *HaproxyAddFrontend) error {
+	n.frontend = *frontend
+	return nil
+}
+
+func (n *fakeBlueGreenNode) GetBackendSwitchingRules(frontend string) (*HaproxyBackendSwitchingRules, error) {
+	return &HaproxyBackendSwitchingRules{Data: append([]HaproxyAddBackendSwitchingRule{}, n.rules...)}, nil
+}
+
+func (n *fakeBlueGreenNode) AddBackendSwitchingRule(frontend string, transactionId string, rule *HaproxyAddBackendSwitchingRule) error {
+	n.rules = append([]HaproxyAddBackendSwitchingRule{*rule}, n.rules...)
+	return nil
+}
+
+func (n *fakeBlueGreenNode) ReplaceBackendSwitchingRule(frontend string, index int, transactionId string, rule *HaproxyAddBackendSwitchingRule) error {
+	n.rules[index] = *rule
+	return nil
+}
+
+func (n *fakeBlueGreenNode) GetConfigurationVersion() (*int, error) {
+	version := 1
+	return &version, nil
+}
+
+func (n *fakeBlueGreenNode) StartTransaction(haproxyVersion string) (*string, error) {
+	n.transactions++
+	transactionId := fmt.Sprintf("tx%d", n.transactions)
+	return &transactionId, nil
+}
+
+func (n *fakeBlueGreenNode) CommitTransactionReload(transactionId string) (string, error) {
+	n.reloads++
+	return fmt.Sprintf("reload%d", n.reloads), nil
+}
+
+func (n *fakeBlueGreenNode) GetReload(id string) (*HaproxyReload, error) {
+	if n.failReloads {
+		return &HaproxyReload{ID: id, Status: ReloadFailed, Response: "[ALERT] parsing error"}, nil
+	}
+	return &HaproxyReload{ID: id, Status: ReloadSucceeded}, nil
+}
+
+func (n *fakeBlueGreenNode) GetStats() (*HaproxyStats, error) {
+	stats := HaproxyNativeStats{}
+	for _, backend := range []string{"blue", "green"} {
+		status := "DOWN"
+		if n.upBackends[backend] {
+			status = "UP"
+		}
+		stats.Stats = append(stats.Stats, HaproxyNativeStat{Type: "server", BackendName: backend, Name: backend + "1", Stats: HaproxyNativeStatValues{Status: status}})
+	}
+	return &HaproxyStats{stats}, nil
+}
+
+func TestBlueGreenSwitchTo(t *testing.T) {
+	tests := []struct {
+		name       string
+		cond       string
+		rules      []HaproxyAddBackendSwitchingRule
+		up         map[string]bool
+		rollback   bool
+		failReload bool
+		target     string
+		active     string // the backend receiving the traffic afterwards
+		reloads    int
+		healthy    bool
+		rolledBack bool
+		err        bool
+	}{
+		{"healthy switch", "", nil, map[string]bool{"green": true}, true, false, "green", "green", 1, true, false, false},
+		{"already active", "", nil, map[string]bool{"blue": true}, true, false, "blue", "blue", 0, true, false, false},
+		{"unhealthy switched back", "", nil, map[string]bool{"blue": true}, true, false, "green", "blue", 2, false, true, true},
+		{"unhealthy kept without rollback", "", nil, map[string]bool{"blue": true}, false, false, "green", "green", 1, false, false, true},
+		{"failed reload", "", nil, map[string]bool{"green": true}, true, true, "green", "green", 1, false, false, true},
+		{"missing rule created", "if", nil, map[string]bool{"green": true}, true, false, "green", "green", 1, true, false, false},
+		{
+			"rule replaced",
+			"if",
+			[]HaproxyAddBackendSwitchingRule{{Name: "other", Cond: "if", CondTest: "is_beta"}, {Name: "blue", Cond: "if", CondTest: "is_beta", Index: 1}},
+			map[string]bool{"green": true},
+			true, false, "green", "green", 1, true, false, false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := &fakeBlueGreenNode{
+				frontend:    HaproxyAddFrontend{Name: "www", DefaultBackend: "blue"},
+				rules:       test.rules,
+				upBackends:  test.up,
+				failReloads: test.failReload,
+			}
+			blueGreen := HaproxyBlueGreen{
+				Client:            node,
+				Frontend:          "www",
+				Blue:              "blue",
+				Green:             "green",
+				HealthTimeout:     time.Nanosecond,
+				RollbackOnFailure: test.rollback,
+			}
+			if test.cond != "" {
+				blueGreen.Cond, blueGreen.CondTest = test.cond, "is_beta"
+			}
+			result, err := blueGreen.SwitchTo(context.Background(), test.target)
+			if (err != nil) != test.err {
+				t.Fatalf("error %v", err)
+			}
+			if result.Healthy != test.healthy || result.RolledBack != test.rolledBack {
+				t.Fatalf("healthy %v rolled back %v, want %v and %v", result.Healthy, result.RolledBack, test.healthy, test.rolledBack)
+			}
+			active, err := blueGreen.Active()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if active != test.active || node.reloads != test.reloads {
+				t.Fatalf("active %s after %d reloads, want %s after %d", active, node.reloads, test.active, test.reloads)
+			}
+			if test.cond != "" && node.frontend.DefaultBackend != "blue" {
+				t.Fatalf("default backend changed to %s", node.frontend.DefaultBackend)
+			}
+		})
+	}
+}
+
+func TestBlueGreenSwitchToUnknownBackend(t *testing.T) {
+	blueGreen := HaproxyBlueGreen{Client: &fakeBlueGreenNode{}, Frontend: "www", Blue: "blue", Green: "green"}
+	if _, err := blueGreen.SwitchTo(context.Background(), "red"); err == nil {
+		t.Fatal("no error for a backend which is neither blue nor green")
+	}
+}
@@ -0,0 +1,213 @@
+package haproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// the highest server weight accepted by haproxy
+const MaxServerWeight = 256
+
+// set the weight of servers of a backend in one transaction, the other server settings are kept.
+//
+// The dataplane api has no runtime endpoint for the weight: the servers are read whole, replaced
+// in the configuration with only their weight changed, and the reload that follows the commit applies it.
+// Returns the id of that reload, see WaitForReload.
+func SetServerWeights(client IHaproxyClient, backend string, weights map[string]int) (string, error) {
+	for name, weight := range weights {
+		if weight < 0 || weight > MaxServerWeight {
+			return "", fmt.Errorf("weight %d of server %s out of range 0-%d", weight, name, MaxServerWeight)
+		}
+	}
+	servers, err := client.GetServers(backend)
+	if err != nil {
+		return "", err
+	}
+	found := map[string]bool{}
+	for _, server := range servers.Data {
+		found[server.Name] = true
+	}
+	for _, name := range sortedKeys(weights) {
+		if !found[name] {
+			return "", fmt.Errorf("server %s not found in backend %s", name, backend)
+		}
+	}
+	return RunTransactionReload(client, func(client IHaproxyClient, transactionId string) error {
+		for _, server := range servers.Data {
+			weight, exist := weights[server.Name]
+			if !exist || (server.Weight != nil && weight == *server.Weight) {
+				continue
+			}
+			server.Weight = &weight
+			if err := client.ReplaceServer(backend, server.Name, transactionId, &server); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// the weights sending percent of the traffic to the canary servers and the rest to the baseline ones
+func CanaryWeights(baseline []string, canary []string, percent int) map[string]int {
+	weights := map[string]int{}
+	share := func(servers []string, percent int) {
+		for _, name := range servers {
+			weight := int(math.Round(float64(percent*MaxServerWeight) / float64(100*len(servers))))
+			if weight == 0 && percent > 0 {
+				weight = 1
+			}
+			weights[name] = weight
+		}
+	}
+	share(baseline, 100-percent)
+	share(canary, percent)
+	return weights
+}
+
+// a step of a canary rollout
+type HaproxyCanaryStep struct {
+	Percent    int       `json:"percent"`
+	ErrorRatio float64   `json:"error_ratio"` // the 5xx ratio of the canary servers during the step
+	Responses  int       `json:"responses"`   // the responses of the canary servers during the step
+	Started    time.Time `json:"started"`
+	Ended      time.Time `json:"ended"`
+}
+
+// shifts the traffic of a backend from the baseline servers to the canary ones in steps (eg: 5, 25,
+// 50, 100 percents), pausing between steps while watching the 5xx ratio of the canary servers.
+// The original weights are restored as soon as the ratio exceeds MaxErrorRatio.
+//
+// The sampler must be running, its interval should be well below Pause.
+type HaproxyCanary struct {
+	Client        IHaproxyClient
+	Sampler       *HaproxyStatsSampler
+	Backend       string
+	Baseline      []string // the servers currently serving the traffic
+	Canary        []string // the servers receiving the new release
+	Steps         []int    // the percents of traffic sent to the canary servers
+	Pause         time.Duration
+	MaxErrorRatio float64
+	MinResponses  int                          // the responses required before comparing the ratio
+	OnStep        func(step HaproxyCanaryStep) // called after every successful step when not nil
+}
+
+var ErrCanaryReverted = errors.New("canary reverted")
+
+// run the rollout and return the steps taken, the last one being the failed step on error. The error
+// wraps ErrCanaryReverted when the weights were restored because of the canary errors, the weights are
+// also restored when the context is cancelled or a step fails.
+func (c *HaproxyCanary) Run(ctx context.Context) ([]HaproxyCanaryStep, error) {
+	if len(c.Baseline) == 0 || len(c.Canary) == 0 || len(c.Steps) == 0 {
+		return nil, errors.New("canary requires baseline servers, canary servers and steps")
+	}
+	if c.Sampler == nil {
+		return nil, errors.New("canary requires a stats sampler")
+	}
+	previous := 0
+	for _, percent := range c.Steps {
+		if percent <= previous || percent > 100 {
+			return nil, fmt.Errorf("canary steps must be increasing percents up to 100, got %v", c.Steps)
+		}
+		previous = percent
+	}
+	original, err := c.weights()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := []HaproxyCanaryStep{}
+	for _, percent := range c.Steps {
+		step, err := c.step(ctx, percent)
+		steps = append(steps, step)
+		if err != nil {
+			if revertErr := c.restore(original); revertErr != nil {
+				return steps, fmt.Errorf("%w, restoring the weights failed: %v", err, revertErr)
+			}
+			return steps, err
+		}
+		if c.OnStep != nil {
+			c.OnStep(step)
+		}
+	}
+	return steps, nil
+}
+
+func (c *HaproxyCanary) step(ctx context.Context, percent int) (HaproxyCanaryStep, error) {
+	step := HaproxyCanaryStep{Percent: percent, Started: time.Now()}
+	reloadId, err := SetServerWeights(c.Client, c.Backend, CanaryWeights(c.Baseline, c.Canary, percent))
+	if err != nil {
+		return step, err
+	}
+	if err := WaitForReload(ctx, c.Client, reloadId, time.Second); err != nil {
+		return step, err
+	}
+	interval := c.Sampler.Interval
+	if interval <= 0 || interval > c.Pause {
+		interval = c.Pause
+	}
+	deadline := time.Now().Add(c.Pause)
+	for {
+		step.Ended = time.Now()
+		step.Responses, step.ErrorRatio = c.errorRatio(step.Ended.Sub(step.Started))
+		if step.Responses > 0 && step.Responses >= c.MinResponses && step.ErrorRatio > c.MaxErrorRatio {
+			return step, fmt.Errorf("%w at %d%%: error ratio %.4f above %.4f", ErrCanaryReverted, percent, step.ErrorRatio, c.MaxErrorRatio)
+		}
+		if !step.Ended.Before(deadline) {
+			return step, nil
+		}
+		select {
+		case <-ctx.Done():
+			return step, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// the 5xx ratio of the canary servers over the window
+func (c *HaproxyCanary) errorRatio(window time.Duration) (int, float64) {
+	responses, failed := 0, 0
+	for _, server := range c.Canary {
+		rates, ok := c.Sampler.Rates(HaproxyStatKey{Type: "server", Proxy: c.Backend, Server: server}, window)
+		if ok {
+			responses += rates.Delta.Responses
+			failed += rates.Delta.Responses5xx
+		}
+	}
+	if responses == 0 {
+		return 0, 0
+	}
+	return responses, float64(failed) / float64(responses)
+}
+
+func (c *HaproxyCanary) weights() (map[string]int, error) {
+	servers, err := c.Client.GetServers(c.Backend)
+	if err != nil {
+		return nil, err
+	}
+	weights := map[string]int{}
+	for _, server := range servers.Data {
+		// haproxy defaults to 1 when the weight is not set
+		weights[server.Name] = 1
+		if server.Weight != nil {
+			weights[server.Name] = *server.Weight
+		}
+	}
+	for _, name := range append(append([]string{}, c.Baseline...), c.Canary...) {
+		if _, exist := weights[name]; !exist {
+			return nil, fmt.Errorf("server %s not found in backend %s", name, c.Backend)
+		}
+	}
+	return weights, nil
+}
+
+func (c *HaproxyCanary) restore(original map[string]int) error {
+	weights := map[string]int{}
+	for _, name := range append(append([]string{}, c.Baseline...), c.Canary...) {
+		weights[name] = original[name]
+	}
+	_, err := SetServerWeights(c.Client, c.Backend, weights)
+	return err
+}
@@ -0,0 +1,29 @@
+package haproxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanaryWeights(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseline []string
+		canary   []string
+		percent  int
+		want     map[string]int
+	}{
+		{"no canary traffic", []string{"a", "b"}, []string{"c"}, 0, map[string]int{"a": 128, "b": 128, "c": 0}},
+		{"first step", []string{"a", "b"}, []string{"c"}, 5, map[string]int{"a": 122, "b": 122, "c": 13}},
+		{"half", []string{"a"}, []string{"c"}, 50, map[string]int{"a": 128, "c": 128}},
+		{"all canary traffic", []string{"a", "b"}, []string{"c", "d"}, 100, map[string]int{"a": 0, "b": 0, "c": 128, "d": 128}},
+		{"a small share keeps a weight of 1", []string{"a"}, []string{"c", "d", "e", "f", "g", "h", "i", "j", "k", "l"}, 1, map[string]int{"a": 253, "c": 1, "d": 1, "e": 1, "f": 1, "g": 1, "h": 1, "i": 1, "j": 1, "k": 1, "l": 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := CanaryWeights(test.baseline, test.canary, test.percent); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("weights %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -34,7 +34,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 )
@@ -47,33 +50,106 @@ type IHaproxyClient interface {
 	GetSites() (*HaproxySites, error)
 	GetStats() (*HaproxyStats, error)
 	GetReloads() (*HaproxyReloads, error)
+	GetReload(id string) (*HaproxyReload, error)
 	GetTransactions() (*HaproxyTransactions, error)
+	GetConfigurationVersion() (*int, error) // the configuration version expected by StartTransaction
+	GetRawConfiguration() (*HaproxyRawConfiguration, error)
+	PushRawConfiguration(version int, raw string) error // replace the whole haproxy cfg
 	GetConfigurationGlobal() (*HaproxyConfigurationGlobal, error)
 	GetConfigurationDefaults() (*HaproxyConfigurationDefaults, error)
+	GetNamedDefaults() (*HaproxyNamedDefaults, error)
+	GetNamedDefault(name string) (*HaproxyNamedDefault, error)
 	GetBackends() (*HaproxyBackends, error)
+	GetBackend(name string) (*HaproxyBackend, error) // the whole backend, replacing it keeps the fields the model has no field for
 	GetFrontends() (*HaproxyFrontends, error)
+	GetFrontend(name string) (*HaproxyFrontend, error) // the whole frontend, replacing it keeps the fields the model has no field for
+	GetBinds(frontend string) (*HaproxyBinds, error)
 	GetBackendSwitchingRules(frontend string) (*HaproxyBackendSwitchingRules, error)
-	GetServers(backend string) (*HaproxyFrontends, error)
+	GetServers(backend string) (*HaproxyServers, error)
+	GetBackendSwitchingRule(frontend string, index int) (*HaproxyBackendSwitchingRule, error)
 	GetAcls(parentType string, parentName string) (*HaproxyAcls, error) //parentType eg: "backend" or "frontend"
+	GetAcl(parentType string, parentName string, index int) (*HaproxyAcl, error)
 	GetServerSwitchingRules(backend string) (*HaproxyServerSwitchingRules, error)
+	GetServerSwitchingRule(backend string, index int) (*HaproxyServerSwitchingRule, error)
 	GetHttpRequestRules(parentType string, parentName string) (*HaproxyHttpRequestRules, error)
+	GetHttpRequestRule(parentType string, parentName string, index int) (*HaproxyHttpRequestRule, error)
+	GetHttpResponseRules(parentType string, parentName string) (*HaproxyHttpResponseRules, error)
+	GetHttpResponseRule(parentType string, parentName string, index int) (*HaproxyHttpResponseRule, error)
+	GetTcpRequestRules(parentType string, parentName string) (*HaproxyTcpRequestRules, error)
+	GetTcpRequestRule(parentType string, parentName string, index int) (*HaproxyTcpRequestRule, error)
+	GetTcpResponseRules(backend string) (*HaproxyTcpResponseRules, error)
+	GetTcpResponseRule(backend string, index int) (*HaproxyTcpResponseRule, error)
 	AddBackend(transactionId string, backend *HaproxyAddBackend) error
+	ReplaceBackend(name string, transactionId string, backend *HaproxyAddBackend) error
+	DeleteBackend(name string, transactionId string) error
 	AddFrontend(transactionId string, addFrontend *HaproxyAddFrontend) error
+	ReplaceFrontend(name string, transactionId string, frontend *HaproxyAddFrontend) error
+	DeleteFrontend(name string, transactionId string) error
+	AddBind(frontend string, transactionId string, addBind *HaproxyAddBind) error
+	ReplaceBind(frontend string, name string, transactionId string, bind *HaproxyAddBind) error
+	DeleteBind(frontend string, name string, transactionId string) error
 	AddAcl(parenttype string, parentName string, transactionId string, addAcl *HaproxyAddAcl) error
+	ReplaceAcl(parentType string, parentName string, index int, transactionId string, acl *HaproxyAddAcl) error
+	DeleteAcl(parentType string, parentName string, index int, transactionId string) error
 	AddServer(backend string, transactionId string, addServer *HaproxyAddServer) error
+	ReplaceServer(backend string, name string, transactionId string, server *HaproxyAddServer) error
+	DeleteServer(backend string, name string, transactionId string) error
 	AddHttpRequestRule(parentType string, parentName string, transactionId string, addRule *HaproxyAddHttpRequestRule) error
+	ReplaceHttpRequestRule(parentType string, parentName string, index int, transactionId string, rule *HaproxyAddHttpRequestRule) error
+	DeleteHttpRequestRule(parentType string, parentName string, index int, transactionId string) error
+	AddHttpResponseRule(parentType string, parentName string, transactionId string, addRule *HaproxyAddHttpResponseRule) error
+	ReplaceHttpResponseRule(parentType string, parentName string, index int, transactionId string, rule *HaproxyAddHttpResponseRule) error
+	DeleteHttpResponseRule(parentType string, parentName string, index int, transactionId string) error
+	AddTcpRequestRule(parentType string, parentName string, transactionId string, addRule *HaproxyAddTcpRequestRule) error
+	ReplaceTcpRequestRule(parentType string, parentName string, index int, transactionId string, rule *HaproxyAddTcpRequestRule) error
+	DeleteTcpRequestRule(parentType string, parentName string, index int, transactionId string) error
+	AddTcpResponseRule(backend string, transactionId string, addRule *HaproxyAddTcpResponseRule) error
+	ReplaceTcpResponseRule(backend string, index int, transactionId string, rule *HaproxyAddTcpResponseRule) error
+	DeleteTcpResponseRule(backend string, index int, transactionId string) error
 	AddBackendSwitchingRule(frontend string, transactionId string, addRule *HaproxyAddBackendSwitchingRule) error
+	ReplaceBackendSwitchingRule(frontend string, index int, transactionId string, rule *HaproxyAddBackendSwitchingRule) error
+	DeleteBackendSwitchingRule(frontend string, index int, transactionId string) error
+	AddServerSwitchingRule(backend string, transactionId string, addRule *HaproxyAddServerSwitchingRule) error
+	ReplaceServerSwitchingRule(backend string, index int, transactionId string, rule *HaproxyAddServerSwitchingRule) error
+	DeleteServerSwitchingRule(backend string, index int, transactionId string) error
+	AddNamedDefaults(transactionId string, addDefaults *HaproxyAddNamedDefaults) error
+	ReplaceNamedDefaults(name string, transactionId string, defaults *HaproxyAddNamedDefaults) error
+	DeleteNamedDefaults(name string, transactionId string) error
+	ReorderAcls(parentType string, parentName string, transactionId string, desired []HaproxyAddAcl) ([]RuleOperation[HaproxyAddAcl], error)
+	ReorderHttpRequestRules(parentType string, parentName string, transactionId string, desired []HaproxyAddHttpRequestRule) ([]RuleOperation[HaproxyAddHttpRequestRule], error)
+	ReorderHttpResponseRules(parentType string, parentName string, transactionId string, desired []HaproxyAddHttpResponseRule) ([]RuleOperation[HaproxyAddHttpResponseRule], error)
+	ReorderTcpRequestRules(parentType string, parentName string, transactionId string, desired []HaproxyAddTcpRequestRule) ([]RuleOperation[HaproxyAddTcpRequestRule], error)
+	ReorderTcpResponseRules(backend string, transactionId string, desired []HaproxyAddTcpResponseRule) ([]RuleOperation[HaproxyAddTcpResponseRule], error)
+	ReorderBackendSwitchingRules(frontend string, transactionId string, desired []HaproxyAddBackendSwitchingRule) ([]RuleOperation[HaproxyAddBackendSwitchingRule], error)
+	ReorderServerSwitchingRules(backend string, transactionId string, desired []HaproxyAddServerSwitchingRule) ([]RuleOperation[HaproxyAddServerSwitchingRule], error)
 	StartTransaction(haproxyVersion string) (*string, error)
 	CommitTransaction(transactionId string) error
+	CommitTransactionReload(transactionId string) (string, error) // commit and return the Reload-ID, empty when no reload was triggered
+	DeleteTransaction(transactionId string) error
+	Reconcile(desired *HaproxyDesiredState) ([]HaproxyChange, error) // apply a desired state in a single transaction
+	Plan(desired *HaproxyDesiredState) (*HaproxyPlan, error) // dry-run of Reconcile
+	ApplyPlan(plan *HaproxyPlan) error
+	Export() (*HaproxyDesiredState, error) // the live configuration as a desired state
 	CheckDuplicateDefinitions() (*HaproxyDuplicateDefinitionsResult, error) // check for duplicate definitions in the haproxy cfg
+	Lint() (*HaproxyLintReport, error) // check the haproxy cfg for dangling references and orphans
+	ImpactOf(resource HaproxyResourceRef) (*HaproxyImpact, error) // what deleting a backend or a server would break
+	Topology(withStats bool) (*HaproxyTopology, error) // the routing graph, rendered with DOT() or Mermaid()
 }
 
 type haproxyClient struct {
 	Url   string
 	Rest  *resty.Client
 	Debug bool
+
+	observersLock sync.RWMutex
+	observers     map[int]HaproxyRequestObserver
+	lastObserver  int
 }
 
+// called once after every dataplane api call of a client. status is 0 and err is set when no response
+// was received, both are set when the response body could not be read or parsed.
+type HaproxyRequestObserver func(method string, status int, duration time.Duration, err error)
+
 // create a new Haproxy client
 //
 // the debug argument is used in case you want to print the request for debug purposes.
@@ -91,12 +167,60 @@ func NewHaproxyClient(haproxyUrl string, basicAuthUsername string, basicAuthPass
 		client.Debug = true
 		log.Println("Debug mode is enabled for the Haproxy client ")
 	}
+	client.Rest.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		client.observe(resp.Request.Method, resp.StatusCode(), resp.Time(), nil)
+		return nil
+	})
+	// the after response hooks run once the body is parsed, a parse failure skips them and only
+	// reaches the error hooks, with the response
+	client.Rest.OnError(func(req *resty.Request, err error) {
+		var responseErr *resty.ResponseError
+		if errors.As(err, &responseErr) {
+			client.observe(req.Method, responseErr.Response.StatusCode(), responseErr.Response.Time(), err)
+			return
+		}
+		client.observe(req.Method, 0, 0, err)
+	})
 	err := client.HealthCheck()
 	if err != nil {
 		return nil, err
 	}
 	return &client, nil
 }
+
+// register an observer of the dataplane api calls made by a client created with NewHaproxyClient,
+// calling remove unregisters it. false is returned for the other IHaproxyClient implementations.
+func ObserveRequests(client IHaproxyClient, observer HaproxyRequestObserver) (remove func(), ok bool) {
+	h, ok := client.(*haproxyClient)
+	if !ok {
+		return func() {}, false
+	}
+	h.observersLock.Lock()
+	defer h.observersLock.Unlock()
+	if h.observers == nil {
+		h.observers = map[int]HaproxyRequestObserver{}
+	}
+	h.lastObserver++
+	id := h.lastObserver
+	h.observers[id] = observer
+	return func() {
+		h.observersLock.Lock()
+		defer h.observersLock.Unlock()
+		delete(h.observers, id)
+	}, true
+}
+
+func (h *haproxyClient) observe(method string, status int, duration time.Duration, err error) {
+	h.observersLock.RLock()
+	observers := make([]HaproxyRequestObserver, 0, len(h.observers))
+	for _, observer := range h.observers {
+		observers = append(observers, observer)
+	}
+	h.observersLock.RUnlock()
+	for _, observer := range observers {
+		observer(method, status, duration, err)
+	}
+}
 /*func (h *haproxyClient) GetBasicInfo() (*HaproxyInfo, error) {
 	if h.Debug {
 		log.Println("GetBasicInfo called() ", h.Url)
@@ -159,12 +283,16 @@ func (h *haproxyClient) GetSites() (*HaproxySites, error) {
 	}
 	url := h.Url + "/v2/services/haproxy/sites"
 	response := HaproxySites{}
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&response).Get(url)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
 		return nil, err
 	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
 	return &response, nil
 }
 
@@ -172,14 +300,18 @@ func (h *haproxyClient) GetStats() (*HaproxyStats, error) {
 	if h.Debug {
 		log.Println("GetStats called()")
 	}
-	url := h.Url + "/v2/services/haproxy/sites"
+	url := h.Url + "/v2/services/haproxy/stats/native"
 	response := HaproxyStats{}
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&response).Get(url)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
 		return nil, err
 	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
 	return &response, nil
 }
 
@@ -189,12 +321,35 @@ func (h *haproxyClient) GetReloads() (*HaproxyReloads, error) {
 	}
 	url := h.Url + "/v2/services/haproxy/reloads"
 	response := HaproxyReloads{}
-	_, err := h.Rest.R().
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *haproxyClient) GetReload(id string) (*HaproxyReload, error) {
+	if h.Debug {
+		log.Println("GetReload called()", id)
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/reloads/%s", id)
+	response := HaproxyReload{}
+	resp, err := h.Rest.R().
 		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
 		SetResult(&response).Get(url)
 	if err != nil {
 		return nil, err
 	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
 	return &response, nil
 }
 
@@ -204,27 +359,91 @@ func (h *haproxyClient) GetTransactions() (*HaproxyTransactions, error) {
 	}
 	url := h.Url + "/v2/services/haproxy/transactions"
 	response := HaproxyTransactions{}
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&response).Get(url)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *haproxyClient) GetConfigurationVersion() (*int, error) {
+	if h.Debug {
+		log.Println("GetConfigurationVersion called()")
+	}
+	url := h.Url + "/v2/services/haproxy/configuration/version"
+	var response int
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
 		return nil, err
 	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *haproxyClient) GetRawConfiguration() (*HaproxyRawConfiguration, error) {
+	if h.Debug {
+		log.Println("GetRawConfiguration called()")
+	}
+	url := h.Url + "/v2/services/haproxy/configuration/raw"
+	response := HaproxyRawConfiguration{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
 	return &response, nil
 }
 
+// the push is refused if version is not the current configuration version
+func (h *haproxyClient) PushRawConfiguration(version int, raw string) error {
+	if h.Debug {
+		log.Println("PushRawConfiguration called()", version)
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/raw?version=%d", version)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "text/plain").
+		SetError(&HaproxyErrorResponse{}).
+		SetBody(raw).Post(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
 func (h *haproxyClient) StartTransaction(haproxyVersion string) (*string, error) {
 	if h.Debug {
 		log.Println("StartTransaction called()")
 	}
 	url := h.Url + fmt.Sprintf("/v2/services/haproxy/transactions?version=%s", haproxyVersion)
 	response := HaproxyTransaction{}
-	_, err := h.Rest.R().
+	resp, err := h.Rest.R().
       SetHeader("Accept", "application/json").
+	  SetError(&HaproxyErrorResponse{}).
 	  SetResult(&response).Post(url)
 	if err != nil {
 		return nil, err
 	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
 	return &response.ID, nil
 }
 
@@ -255,15 +474,57 @@ func (h *haproxyClient) GetConfigurationDefaults() (*HaproxyConfigurationDefault
 	return &response, nil
 }
 
+// list the named defaults sections (HAProxy >= 2.4), frontends and backends refer to them by the "from" field
+func (h *haproxyClient) GetNamedDefaults() (*HaproxyNamedDefaults, error) {
+	if h.Debug {
+		log.Println("GetNamedDefaults called()")
+	}
+	url := h.Url + "/v2/services/haproxy/configuration/named_defaults"
+	response := HaproxyNamedDefaults{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *haproxyClient) GetNamedDefault(name string) (*HaproxyNamedDefault, error) {
+	if h.Debug {
+		log.Println("GetNamedDefault called()", name)
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/named_defaults/%s", name)
+	response := HaproxyNamedDefault{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
 func (h *haproxyClient) GetBackends() (*HaproxyBackends, error) {
 	url := h.Url + "/v2/services/haproxy/configuration/backends"
 	response := HaproxyBackends{}
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&response).Get(url)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
 		return nil, err
-		//.(*HaproxyErrorResponse)
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
 	}
 	return &response, nil
 }
@@ -271,12 +532,63 @@ func (h *haproxyClient) GetBackends() (*HaproxyBackends, error) {
 func (h *haproxyClient) GetFrontends() (*HaproxyFrontends, error) {
 	url := h.Url + "/v2/services/haproxy/configuration/frontends"
 	response := HaproxyFrontends{}
-	_, err := h.Rest.R().
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *haproxyClient) GetBackend(name string) (*HaproxyBackend, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/backends/%s", name)
+	response := HaproxyBackend{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *haproxyClient) GetFrontend(name string) (*HaproxyFrontend, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/frontends/%s", name)
+	response := HaproxyFrontend{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *haproxyClient) GetBinds(frontend string) (*HaproxyBinds, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/binds?frontend=%s", frontend)
+	response := HaproxyBinds{}
+	resp, err := h.Rest.R().
 		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
 		SetResult(&response).Get(url)
 	if err != nil {
 		return nil, err
-		//.(*HaproxyErrorResponse)
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
 	}
 	return &response, nil
 }
@@ -284,25 +596,31 @@ func (h *haproxyClient) GetFrontends() (*HaproxyFrontends, error) {
 func (h *haproxyClient) GetBackendSwitchingRules(frontend string) (*HaproxyBackendSwitchingRules, error) {
 	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/backend_switching_rules?frontend=%s", frontend)
 	response := HaproxyBackendSwitchingRules{}
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&response).Get(url)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
 		return nil, err
-		//.(*HaproxyErrorResponse)
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
 	}
 	return &response, nil
 }
 
-func (h *haproxyClient) GetServers(backend string) (*HaproxyFrontends, error) {
+func (h *haproxyClient) GetServers(backend string) (*HaproxyServers, error) {
 	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/servers?backend=%s", backend)
-	response := HaproxyFrontends{}
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&response).Get(url)
+	response := HaproxyServers{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
 		return nil, err
-		//.(*HaproxyErrorResponse)
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
 	}
 	return &response, nil
 }
@@ -311,177 +629,873 @@ func (h *haproxyClient) GetServers(backend string) (*HaproxyFrontends, error) {
 func (h *haproxyClient) GetAcls(parentType string, parentName string) (*HaproxyAcls, error) {
 	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/acls?parent_type=%s&parent_name=%s", parentType, parentName)
 	response := HaproxyAcls{}
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&response).Get(url)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
 		return nil, err
 	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
 	return &response, nil
 }
 
 func (h *haproxyClient) GetServerSwitchingRules(backend string) (*HaproxyServerSwitchingRules, error) {
 	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/server_switching_rules?backend=%s", backend)
 	response := HaproxyServerSwitchingRules{}
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&response).Get(url)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
 		return nil, err
 	}
-
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
 	return &response, nil
 }
 
 func (h *haproxyClient) GetHttpRequestRules(parentType string, parentName string) (*HaproxyHttpRequestRules, error) {
 	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/http_request_rules?parent_type=%s&parent_name=%s", parentType, parentName)
 	response := HaproxyHttpRequestRules{}
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&response).Get(url)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
 		return nil, err
 	}
-
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
 	return &response, nil
 }
 
-func (h *haproxyClient) AddFrontend(transactionId string, addFrontend *HaproxyAddFrontend) error {
-	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/frontends?transaction_id=%s", transactionId)
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&HaproxyAddFrontend{}).SetBody(addFrontend).Post(url)
+func (h *haproxyClient) GetBackendSwitchingRule(frontend string, index int) (*HaproxyBackendSwitchingRule, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/backend_switching_rules/%d?frontend=%s", index, frontend)
+	response := HaproxyBackendSwitchingRule{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return nil
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
-func (h *haproxyClient) AddBackend(transactionId string, addBackend *HaproxyAddBackend) error {
-	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/backends?transaction_id=%s", transactionId)
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&HaproxyAddBackend{}).SetBody(addBackend).Post(url)
+func (h *haproxyClient) GetAcl(parentType string, parentName string, index int) (*HaproxyAcl, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/acls/%d?parent_type=%s&parent_name=%s", index, parentType, parentName)
+	response := HaproxyAcl{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return nil
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
-func (h *haproxyClient) AddAcl(parenttype string, parentName string, transactionId string, addAcl *HaproxyAddAcl) error {
-	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/acls?parent_type=%s&parent_name=%s&transaction_id=%s", parenttype, parentName, transactionId)
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&HaproxyAddAcl{}).SetBody(addAcl).Post(url)
+func (h *haproxyClient) GetServerSwitchingRule(backend string, index int) (*HaproxyServerSwitchingRule, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/server_switching_rules/%d?backend=%s", index, backend)
+	response := HaproxyServerSwitchingRule{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return nil
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
-func (h *haproxyClient) AddServer(backend string, transactionId string, addServer *HaproxyAddServer) error {
-	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/servers?backend=%s&transaction_id=%s", backend, transactionId)
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&HaproxyAddServer{}).SetBody(addServer).Post(url)
+func (h *haproxyClient) GetHttpRequestRule(parentType string, parentName string, index int) (*HaproxyHttpRequestRule, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/http_request_rules/%d?parent_type=%s&parent_name=%s", index, parentType, parentName)
+	response := HaproxyHttpRequestRule{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// parent type: backend or frontend
+func (h *haproxyClient) GetHttpResponseRules(parentType string, parentName string) (*HaproxyHttpResponseRules, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/http_response_rules?parent_type=%s&parent_name=%s", parentType, parentName)
+	response := HaproxyHttpResponseRules{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *haproxyClient) GetHttpResponseRule(parentType string, parentName string, index int) (*HaproxyHttpResponseRule, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/http_response_rules/%d?parent_type=%s&parent_name=%s", index, parentType, parentName)
+	response := HaproxyHttpResponseRule{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// parent type: backend or frontend
+func (h *haproxyClient) GetTcpRequestRules(parentType string, parentName string) (*HaproxyTcpRequestRules, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/tcp_request_rules?parent_type=%s&parent_name=%s", parentType, parentName)
+	response := HaproxyTcpRequestRules{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *haproxyClient) GetTcpRequestRule(parentType string, parentName string, index int) (*HaproxyTcpRequestRule, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/tcp_request_rules/%d?parent_type=%s&parent_name=%s", index, parentType, parentName)
+	response := HaproxyTcpRequestRule{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// tcp-response rules are only allowed in backends, hence no parent type
+func (h *haproxyClient) GetTcpResponseRules(backend string) (*HaproxyTcpResponseRules, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/tcp_response_rules?backend=%s", backend)
+	response := HaproxyTcpResponseRules{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *haproxyClient) GetTcpResponseRule(backend string, index int) (*HaproxyTcpResponseRule, error) {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/tcp_response_rules/%d?backend=%s", index, backend)
+	response := HaproxyTcpResponseRule{}
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&response).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *haproxyClient) AddFrontend(transactionId string, addFrontend *HaproxyAddFrontend) error {
+	if err := addFrontend.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/frontends?transaction_id=%s", transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddFrontend{}).SetBody(addFrontend).Post(url)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceFrontend(name string, transactionId string, frontend *HaproxyAddFrontend) error {
+	if err := frontend.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/frontends/%s?transaction_id=%s", name, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddFrontend{}).SetBody(frontend).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) DeleteFrontend(name string, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/frontends/%s?transaction_id=%s", name, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) AddBind(frontend string, transactionId string, addBind *HaproxyAddBind) error {
+	if err := addBind.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/binds?frontend=%s&transaction_id=%s", frontend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddBind{}).SetBody(addBind).Post(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceBind(frontend string, name string, transactionId string, bind *HaproxyAddBind) error {
+	if err := bind.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/binds/%s?frontend=%s&transaction_id=%s", name, frontend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddBind{}).SetBody(bind).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) DeleteBind(frontend string, name string, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/binds/%s?frontend=%s&transaction_id=%s", name, frontend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) AddBackend(transactionId string, addBackend *HaproxyAddBackend) error {
+	if err := addBackend.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/backends?transaction_id=%s", transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddBackend{}).SetBody(addBackend).Post(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceBackend(name string, transactionId string, backend *HaproxyAddBackend) error {
+	if err := backend.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/backends/%s?transaction_id=%s", name, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddBackend{}).SetBody(backend).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) DeleteBackend(name string, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/backends/%s?transaction_id=%s", name, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) AddAcl(parenttype string, parentName string, transactionId string, addAcl *HaproxyAddAcl) error {
+	if err := addAcl.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/acls?parent_type=%s&parent_name=%s&transaction_id=%s", parenttype, parentName, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddAcl{}).SetBody(addAcl).Post(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceAcl(parentType string, parentName string, index int, transactionId string, acl *HaproxyAddAcl) error {
+	if err := acl.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/acls/%d?parent_type=%s&parent_name=%s&transaction_id=%s", index, parentType, parentName, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddAcl{}).SetBody(acl).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) DeleteAcl(parentType string, parentName string, index int, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/acls/%d?parent_type=%s&parent_name=%s&transaction_id=%s", index, parentType, parentName, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) AddServer(backend string, transactionId string, addServer *HaproxyAddServer) error {
+	if err := addServer.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/servers?backend=%s&transaction_id=%s", backend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddServer{}).SetBody(addServer).Post(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceServer(backend string, name string, transactionId string, server *HaproxyAddServer) error {
+	if err := server.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/servers/%s?backend=%s&transaction_id=%s", name, backend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddServer{}).SetBody(server).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) DeleteServer(backend string, name string, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/servers/%s?backend=%s&transaction_id=%s", name, backend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
 }
 
 func (h *haproxyClient) AddHttpRequestRule(parentType string, parentName string, transactionId string, addRule *HaproxyAddHttpRequestRule) error {
+	if err := addRule.Validate(); err != nil {
+		return err
+	}
 	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/http_request_rules?parent_type=%s&parent_name=%s&transaction_id=%s", parentType, parentName, transactionId)
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&HaproxyAddHttpRequestRule{}).SetBody(addRule).Post(url)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddHttpRequestRule{}).SetBody(addRule).Post(url)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceHttpRequestRule(parentType string, parentName string, index int, transactionId string, rule *HaproxyAddHttpRequestRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/http_request_rules/%d?parent_type=%s&parent_name=%s&transaction_id=%s", index, parentType, parentName, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddHttpRequestRule{}).SetBody(rule).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) DeleteHttpRequestRule(parentType string, parentName string, index int, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/http_request_rules/%d?parent_type=%s&parent_name=%s&transaction_id=%s", index, parentType, parentName, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+// the rule is inserted at addRule.Index, the following rules are shifted down
+func (h *haproxyClient) AddHttpResponseRule(parentType string, parentName string, transactionId string, addRule *HaproxyAddHttpResponseRule) error {
+	if err := addRule.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/http_response_rules?parent_type=%s&parent_name=%s&transaction_id=%s", parentType, parentName, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddHttpResponseRule{}).SetBody(addRule).Post(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceHttpResponseRule(parentType string, parentName string, index int, transactionId string, rule *HaproxyAddHttpResponseRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/http_response_rules/%d?parent_type=%s&parent_name=%s&transaction_id=%s", index, parentType, parentName, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddHttpResponseRule{}).SetBody(rule).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+// the rules after index are shifted up
+func (h *haproxyClient) DeleteHttpResponseRule(parentType string, parentName string, index int, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/http_response_rules/%d?parent_type=%s&parent_name=%s&transaction_id=%s", index, parentType, parentName, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) AddTcpRequestRule(parentType string, parentName string, transactionId string, addRule *HaproxyAddTcpRequestRule) error {
+	if err := addRule.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/tcp_request_rules?parent_type=%s&parent_name=%s&transaction_id=%s", parentType, parentName, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddTcpRequestRule{}).SetBody(addRule).Post(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceTcpRequestRule(parentType string, parentName string, index int, transactionId string, rule *HaproxyAddTcpRequestRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/tcp_request_rules/%d?parent_type=%s&parent_name=%s&transaction_id=%s", index, parentType, parentName, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddTcpRequestRule{}).SetBody(rule).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) DeleteTcpRequestRule(parentType string, parentName string, index int, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/tcp_request_rules/%d?parent_type=%s&parent_name=%s&transaction_id=%s", index, parentType, parentName, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) AddTcpResponseRule(backend string, transactionId string, addRule *HaproxyAddTcpResponseRule) error {
+	if err := addRule.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/tcp_response_rules?backend=%s&transaction_id=%s", backend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddTcpResponseRule{}).SetBody(addRule).Post(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceTcpResponseRule(backend string, index int, transactionId string, rule *HaproxyAddTcpResponseRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/tcp_response_rules/%d?backend=%s&transaction_id=%s", index, backend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddTcpResponseRule{}).SetBody(rule).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) DeleteTcpResponseRule(backend string, index int, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/tcp_response_rules/%d?backend=%s&transaction_id=%s", index, backend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
 }
 
 func (h *haproxyClient) AddBackendSwitchingRule(frontend string, transactionId string, addRule *HaproxyAddBackendSwitchingRule) error {
+	if err := addRule.Validate(); err != nil {
+		return err
+	}
 	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/backend_switching_rules?frontend=%s&transaction_id=%s", frontend, transactionId)
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&HaproxyAddBackendSwitchingRule{}).SetBody(addRule).Post(url)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddBackendSwitchingRule{}).SetBody(addRule).Post(url)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceBackendSwitchingRule(frontend string, index int, transactionId string, rule *HaproxyAddBackendSwitchingRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/backend_switching_rules/%d?frontend=%s&transaction_id=%s", index, frontend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddBackendSwitchingRule{}).SetBody(rule).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) DeleteBackendSwitchingRule(frontend string, index int, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/backend_switching_rules/%d?frontend=%s&transaction_id=%s", index, frontend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) AddServerSwitchingRule(backend string, transactionId string, addRule *HaproxyAddServerSwitchingRule) error {
+	if err := addRule.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/server_switching_rules?backend=%s&transaction_id=%s", backend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddServerSwitchingRule{}).SetBody(addRule).Post(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceServerSwitchingRule(backend string, index int, transactionId string, rule *HaproxyAddServerSwitchingRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/server_switching_rules/%d?backend=%s&transaction_id=%s", index, backend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddServerSwitchingRule{}).SetBody(rule).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) DeleteServerSwitchingRule(backend string, index int, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/server_switching_rules/%d?backend=%s&transaction_id=%s", index, backend, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) AddNamedDefaults(transactionId string, addDefaults *HaproxyAddNamedDefaults) error {
+	if err := addDefaults.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/named_defaults?transaction_id=%s", transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddNamedDefaults{}).SetBody(addDefaults).Post(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) ReplaceNamedDefaults(name string, transactionId string, defaults *HaproxyAddNamedDefaults) error {
+	if err := defaults.Validate(); err != nil {
+		return err
+	}
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/named_defaults/%s?transaction_id=%s", name, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyAddNamedDefaults{}).SetBody(defaults).Put(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
+}
+
+func (h *haproxyClient) DeleteNamedDefaults(name string, transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/named_defaults/%s?transaction_id=%s", name, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
 }
 
 func (h *haproxyClient) CommitTransaction(transactionId string) error {
+	_, err := h.CommitTransactionReload(transactionId)
+	return err
+}
+
+// the dataplane api answers 202 with a Reload-ID header when the commit triggers a reload,
+// see WaitForReload
+func (h *haproxyClient) CommitTransactionReload(transactionId string) (string, error) {
 	url := h.Url + fmt.Sprintf("/v2/services/haproxy/transactions/%s", transactionId)
-	_, err := h.Rest.R().
-      SetHeader("Accept", "application/json").
-	  SetResult(&HaproxyCommitTransaction{}).Put(url)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(&HaproxyCommitTransaction{}).Put(url)
 	if err != nil {
-		return err.(*HaproxyErrorResponse)
+		return "", err
 	}
+	if err := responseError(resp); err != nil {
+		return "", err
+	}
+	return resp.Header().Get("Reload-ID"), nil
+}
 
-	return nil
+// discard a transaction that will not be committed
+func (h *haproxyClient) DeleteTransaction(transactionId string) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/transactions/%s", transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		Delete(url)
+	if err != nil {
+		return err
+	}
+
+	return responseError(resp)
 }
 
+// report the backends and frontends defined more than once, and the acls and servers
+// defined more than once within the same parent
 func (h *haproxyClient) CheckDuplicateDefinitions() (*HaproxyDuplicateDefinitionsResult, error) {
-	result := HaproxyDuplicateDefinitionsResult{}
-
-	// //BACKENDS
-	// backends, err := h.GetBackends()
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// backendsNames := []string{}
-	// for _, v := range backends.Data {
-	// 	backendsNames = append(backendsNames, v.Name)
-	// }
-	// result.Backends = dupesCheck(backendsNames)
-
-	// //FRONTENDS
-	// frontends, err := h.GetFrontends()
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// frontendNames := []string{}
-	// for _, v := range frontends.Data {
-	// 	frontendNames = append(frontendNames, v.Name)
-	// }
-	// result.Frontends = dupesCheck(frontendNames)
-
-	// //ACLS
-	//FOR EACH BACKEND and FRONTEND
-	// acls, err := h.GetAcls("backend", "gpu-f-c0-1")
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// aclsnames := []string{}
-	// for _, v := range acls.Data {
-	// 	aclsnames = append(aclsnames, v.AclName)
-	// }
-	// result.Acls = dupesCheck(aclsnames)
-
-	//SERVERS
-	//FOR EACH BACKEND
-	// servers, err := h.GetServers("gpu-f-c0-1")
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// serversNames := []string{}
-	// for _, v := range servers.Data {
-	// 	serversNames = append(serversNames, v.Name)
-	// }
-	// result.Servers = dupesCheck(serversNames)
+	result := HaproxyDuplicateDefinitionsResult{
+		Acls:    []DuplicateCount{},
+		Servers: []DuplicateCount{},
+	}
+
+	//BACKENDS
+	backends, err := h.GetBackends()
+	if err != nil {
+		return nil, err
+	}
+	backendsNames := []string{}
+	for _, v := range backends.Data {
+		backendsNames = append(backendsNames, v.Name)
+	}
+	result.Backends = dupesCheck(backendsNames)
+
+	//FRONTENDS
+	frontends, err := h.GetFrontends()
+	if err != nil {
+		return nil, err
+	}
+	frontendNames := []string{}
+	for _, v := range frontends.Data {
+		frontendNames = append(frontendNames, v.Name)
+	}
+	result.Frontends = dupesCheck(frontendNames)
+
+	//ACLS, FOR EACH FRONTEND AND BACKEND
+	parents := map[string][]string{"frontend": frontendNames, "backend": backendsNames}
+	for _, parentType := range []string{"frontend", "backend"} {
+		for _, parentName := range parents[parentType] {
+			acls, err := h.GetAcls(parentType, parentName)
+			if err != nil {
+				return nil, err
+			}
+			aclsNames := []string{}
+			for _, v := range acls.Data {
+				aclsNames = append(aclsNames, v.AclName)
+			}
+			result.Acls = append(result.Acls, withParent(dupesCheck(aclsNames), parentType, parentName)...)
+		}
+	}
+
+	//SERVERS, FOR EACH BACKEND
+	for _, backend := range backendsNames {
+		servers, err := h.GetServers(backend)
+		if err != nil {
+			return nil, err
+		}
+		serversNames := []string{}
+		for _, v := range servers.Data {
+			serversNames = append(serversNames, v.Name)
+		}
+		result.Servers = append(result.Servers, withParent(dupesCheck(serversNames), "backend", backend)...)
+	}
 
 	return &result, nil
 }
 
+func withParent(dupes []DuplicateCount, parentType string, parentName string) []DuplicateCount {
+	for i := range dupes {
+		dupes[i].ParentType = parentType
+		dupes[i].ParentName = parentName
+	}
+	return dupes
+}
+
+// returns the error carried by a non 2xx dataplane response, nil otherwise
+func responseError(resp *resty.Response) error {
+	if !resp.IsError() {
+		return nil
+	}
+	if e, ok := resp.Error().(*HaproxyErrorResponse); ok && e.Message != "" {
+		return e
+	}
+	return fmt.Errorf("haproxy dataplane request failed: %s", resp.Status())
+}
+
 func dupesCheck(list []string) []DuplicateCount {
 	duplicate_frequency := make(map[string]int)
 	result := []DuplicateCount{}
@@ -505,6 +1519,7 @@ func dupesCheck(list []string) []DuplicateCount {
 			result = append(result, res)
 		}
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
 
 	return result
 }
@@ -0,0 +1,55 @@
+package haproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// a dataplane answering every request with a 500 and an error body
+func failingDataplane(t *testing.T) *haproxyClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"code":500,"message":"configuration file is locked"}`))
+	}))
+	t.Cleanup(server.Close)
+	return &haproxyClient{Url: server.URL, Rest: resty.New()}
+}
+
+func TestListGettersStatus(t *testing.T) {
+	client := failingDataplane(t)
+	tests := []struct {
+		name string
+		get  func() error
+	}{
+		{"sites", func() error { _, err := client.GetSites(); return err }},
+		{"reloads", func() error { _, err := client.GetReloads(); return err }},
+		{"transactions", func() error { _, err := client.GetTransactions(); return err }},
+		{"backends", func() error { _, err := client.GetBackends(); return err }},
+		{"frontends", func() error { _, err := client.GetFrontends(); return err }},
+		{"binds", func() error { _, err := client.GetBinds("www"); return err }},
+		{"servers", func() error { _, err := client.GetServers("app"); return err }},
+		{"acls", func() error { _, err := client.GetAcls("frontend", "www"); return err }},
+		{"backend switching rules", func() error { _, err := client.GetBackendSwitchingRules("www"); return err }},
+		{"server switching rules", func() error { _, err := client.GetServerSwitchingRules("app"); return err }},
+		{"http request rules", func() error { _, err := client.GetHttpRequestRules("frontend", "www"); return err }},
+		{"http response rules", func() error { _, err := client.GetHttpResponseRules("frontend", "www"); return err }},
+		{"tcp request rules", func() error { _, err := client.GetTcpRequestRules("frontend", "www"); return err }},
+		{"tcp response rules", func() error { _, err := client.GetTcpResponseRules("app"); return err }},
+		{"named defaults", func() error { _, err := client.GetNamedDefaults(); return err }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.get()
+			if err == nil {
+				t.Fatal("no error for a 500 response")
+			}
+			if err.Error() != "configuration file is locked" {
+				t.Fatalf("error %q, want the dataplane message", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package haproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+type ClusterPolicy string
+
+const (
+	// the change is committed on every node or on none: the transactions are prepared everywhere before
+	// committing, and the nodes already committed are restored from their raw configuration snapshot if a commit fails
+	ClusterAllOrNothing ClusterPolicy = "all-or-nothing"
+	// every node commits on its own, the failures are only reported
+	ClusterBestEffort ClusterPolicy = "best-effort"
+)
+
+// the outcome of an operation on a node, the errors are rendered as their message in JSON
+type HaproxyNodeResult struct {
+	Node        string `json:"node"`
+	Err         error  `json:"error,omitempty"`
+	RolledBack  bool   `json:"rolled_back"`              // the node was restored to its snapshot
+	RollbackErr error  `json:"rollback_error,omitempty"` // the restore failed, the node must be fixed by hand
+	CleanupErr  error  `json:"cleanup_error,omitempty"`  // the transaction could not be deleted and is left on the node
+}
+
+func (r HaproxyNodeResult) MarshalJSON() ([]byte, error) {
+	type plain HaproxyNodeResult
+	body := struct {
+		plain
+		Err         string `json:"error,omitempty"`
+		RollbackErr string `json:"rollback_error,omitempty"`
+		CleanupErr  string `json:"cleanup_error,omitempty"`
+	}{plain: plain(r)}
+	if r.Err != nil {
+		body.Err = r.Err.Error()
+	}
+	if r.RollbackErr != nil {
+		body.RollbackErr = r.RollbackErr.Error()
+	}
+	if r.CleanupErr != nil {
+		body.CleanupErr = r.CleanupErr.Error()
+	}
+	return json.Marshal(body)
+}
+
+// fans reads and transactional writes out to several haproxy nodes
+type HaproxyCluster struct {
+	Policy ClusterPolicy
+	nodes  map[string]IHaproxyClient
+}
+
+// create a cluster from existing clients, nodes are keyed by a name used in the results
+func NewHaproxyCluster(nodes map[string]IHaproxyClient, policy ClusterPolicy) *HaproxyCluster {
+	return &HaproxyCluster{Policy: policy, nodes: nodes}
+}
+
+// create a cluster sharing the same credentials, nodes are keyed by their url
+//
+// example usage:
+//
+// cluster, err := haproxy.NewHaproxyClusterFromUrls([]string{"http://lb1:5555", "http://lb2:5555"}, "user", "password", false, haproxy.ClusterAllOrNothing)
+func NewHaproxyClusterFromUrls(haproxyUrls []string, basicAuthUsername string, basicAuthPassword string, debug bool, policy ClusterPolicy) (*HaproxyCluster, error) {
+	nodes := map[string]IHaproxyClient{}
+	for _, url := range haproxyUrls {
+		client, err := NewHaproxyClient(url, basicAuthUsername, basicAuthPassword, debug)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", url, err)
+		}
+		nodes[url] = client
+	}
+	return NewHaproxyCluster(nodes, policy), nil
+}
+
+func (c *HaproxyCluster) Nodes() map[string]IHaproxyClient {
+	return c.nodes
+}
+
+// run fn concurrently on every node
+func (c *HaproxyCluster) Each(fn func(node string, client IHaproxyClient) error) []HaproxyNodeResult {
+	return c.run(sortedKeys(c.nodes), func(node string) error {
+		return fn(node, c.nodes[node])
+	})
+}
+
+// run a read concurrently on every node, eg: haproxy.ClusterGet(cluster, haproxy.IHaproxyClient.GetBackends)
+func ClusterGet[T any](c *HaproxyCluster, get func(client IHaproxyClient) (T, error)) (map[string]T, []HaproxyNodeResult) {
+	values := map[string]T{}
+	var lock sync.Mutex
+	results := c.Each(func(node string, client IHaproxyClient) error {
+		value, err := get(client)
+		if err == nil {
+			lock.Lock()
+			values[node] = value
+			lock.Unlock()
+		}
+		return err
+	})
+	return values, results
+}
+
+// apply fn within a transaction on every node according to the cluster policy,
+// the error reports how many nodes failed and the results give the details per node.
+// The transactions which are not committed are deleted.
+func (c *HaproxyCluster) Transaction(fn func(client IHaproxyClient, transactionId string) error) ([]HaproxyNodeResult, error) {
+	nodes := sortedKeys(c.nodes)
+	transactions := map[string]string{}
+	var lock sync.Mutex
+	if c.Policy != ClusterAllOrNothing {
+		results := c.run(nodes, func(node string) error {
+			client := c.nodes[node]
+			transactionId, err := prepareTransaction(client, fn)
+			if err != nil {
+				return err
+			}
+			lock.Lock()
+			transactions[node] = transactionId
+			lock.Unlock()
+			return client.CommitTransaction(transactionId)
+		})
+		c.deleteTransactions(results, failedTransactions(results, transactions))
+		return results, clusterError(results)
+	}
+
+	// snapshot and prepare every node before committing anything
+	snapshots := map[string]*HaproxyRawConfiguration{}
+	results := c.run(nodes, func(node string) error {
+		client := c.nodes[node]
+		snapshot, err := client.GetRawConfiguration()
+		if err != nil {
+			return err
+		}
+		transactionId, err := prepareTransaction(client, fn)
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		snapshots[node] = snapshot
+		transactions[node] = transactionId
+		return nil
+	})
+	if err := clusterError(results); err != nil {
+		c.deleteTransactions(results, transactions)
+		return results, err
+	}
+
+	results = c.run(nodes, func(node string) error {
+		return c.nodes[node].CommitTransaction(transactions[node])
+	})
+	if err := clusterError(results); err != nil {
+		c.deleteTransactions(results, failedTransactions(results, transactions))
+		c.rollback(results, snapshots)
+		return results, err
+	}
+	return results, nil
+}
+
+// delete the given transactions, a failure is reported as the CleanupErr of the node
+func (c *HaproxyCluster) deleteTransactions(results []HaproxyNodeResult, transactions map[string]string) {
+	for i := range results {
+		if transactionId, exist := transactions[results[i].Node]; exist {
+			results[i].CleanupErr = c.nodes[results[i].Node].DeleteTransaction(transactionId)
+		}
+	}
+}
+
+// the transactions of the nodes which failed
+func failedTransactions(results []HaproxyNodeResult, transactions map[string]string) map[string]string {
+	failed := map[string]string{}
+	for _, result := range results {
+		if transactionId, exist := transactions[result.Node]; exist && result.Err != nil {
+			failed[result.Node] = transactionId
+		}
+	}
+	return failed
+}
+
+// reconcile every node to the same desired state according to the cluster policy
+func (c *HaproxyCluster) Reconcile(desired *HaproxyDesiredState) ([]HaproxyNodeResult, error) {
+	nodes := sortedKeys(c.nodes)
+	snapshots := map[string]*HaproxyRawConfiguration{}
+	var lock sync.Mutex
+	results := c.run(nodes, func(node string) error {
+		client := c.nodes[node]
+		if c.Policy == ClusterAllOrNothing {
+			snapshot, err := client.GetRawConfiguration()
+			if err != nil {
+				return err
+			}
+			lock.Lock()
+			snapshots[node] = snapshot
+			lock.Unlock()
+		}
+		_, err := client.Reconcile(desired)
+		return err
+	})
+	if err := clusterError(results); err != nil {
+		if c.Policy == ClusterAllOrNothing {
+			c.rollback(results, snapshots)
+		}
+		return results, err
+	}
+	return results, nil
+}
+
+// push back the raw configuration snapshot of the nodes which succeeded
+func (c *HaproxyCluster) rollback(results []HaproxyNodeResult, snapshots map[string]*HaproxyRawConfiguration) {
+	var wait sync.WaitGroup
+	for i := range results {
+		snapshot, exist := snapshots[results[i].Node]
+		if results[i].Err != nil || !exist {
+			continue
+		}
+		wait.Add(1)
+		go func(result *HaproxyNodeResult) {
+			defer wait.Done()
+			result.RollbackErr = restoreRawConfiguration(c.nodes[result.Node], snapshot)
+			result.RolledBack = result.RollbackErr == nil
+		}(&results[i])
+	}
+	wait.Wait()
+}
+
+// push the snapshot on the current configuration version of the node
+func restoreRawConfiguration(client IHaproxyClient, snapshot *HaproxyRawConfiguration) error {
+	version, err := client.GetConfigurationVersion()
+	if err != nil {
+		return err
+	}
+	return client.PushRawConfiguration(*version, stripVersionLine(snapshot.Data))
+}
+
+func (c *HaproxyCluster) run(nodes []string, fn func(node string) error) []HaproxyNodeResult {
+	results := make([]HaproxyNodeResult, len(nodes))
+	var wait sync.WaitGroup
+	for i, node := range nodes {
+		wait.Add(1)
+		go func(i int, node string) {
+			defer wait.Done()
+			results[i] = HaproxyNodeResult{Node: node, Err: fn(node)}
+		}(i, node)
+	}
+	wait.Wait()
+	return results
+}
+
+// run fn in a transaction on the current configuration version of a single node and commit it,
+// the transaction is discarded if fn fails
+func RunTransaction(client IHaproxyClient, fn func(client IHaproxyClient, transactionId string) error) error {
+	_, err := RunTransactionReload(client, fn)
+	return err
+}
+
+// RunTransaction returning the id of the reload triggered by the commit, see WaitForReload
+func RunTransactionReload(client IHaproxyClient, fn func(client IHaproxyClient, transactionId string) error) (string, error) {
+	transactionId, err := prepareTransaction(client, fn)
+	if err != nil {
+		return "", err
+	}
+	return client.CommitTransactionReload(transactionId)
+}
+
+// start a transaction on the current configuration version and run fn in it, the transaction is
+// discarded if fn fails
+func prepareTransaction(client IHaproxyClient, fn func(client IHaproxyClient, transactionId string) error) (string, error) {
+	version, err := client.GetConfigurationVersion()
+	if err != nil {
+		return "", err
+	}
+	transactionId, err := client.StartTransaction(strconv.Itoa(*version))
+	if err != nil {
+		return "", err
+	}
+	if err := fn(client, *transactionId); err != nil {
+		if deleteErr := client.DeleteTransaction(*transactionId); deleteErr != nil {
+			return "", fmt.Errorf("%w (the transaction %s is left: %v)", err, *transactionId, deleteErr)
+		}
+		return "", err
+	}
+	return *transactionId, nil
+}
+
+func clusterError(results []HaproxyNodeResult) error {
+	failed := 0
+	var first error
+	for _, result := range results {
+		if result.Err != nil {
+			if first == nil {
+				first = fmt.Errorf("node %s: %w", result.Node, result.Err)
+			}
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d nodes failed, %w", failed, len(results), first)
+}
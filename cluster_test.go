@@ -0,0 +1,193 @@
+package haproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// a node answering the transaction and raw configuration calls, failing at the given step
+type fakeClusterNode struct {
+	IHaproxyClient
+	failAt string // "snapshot", "prepare", "commit" or "delete" (with a failed commit)
+	raw    string
+	lock   sync.Mutex
+	calls  []string
+}
+
+func (n *fakeClusterNode) record(call string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.calls = append(n.calls, call)
+}
+
+func (n *fakeClusterNode) GetRawConfiguration() (*HaproxyRawConfiguration, error) {
+	if n.failAt == "snapshot" {
+		return nil, errors.New("unreachable")
+	}
+	return &HaproxyRawConfiguration{Version: 3, Data: "# _version=3\n" + n.raw}, nil
+}
+
+func (n *fakeClusterNode) GetConfigurationVersion() (*int, error) {
+	version := 4
+	return &version, nil
+}
+
+func (n *fakeClusterNode) StartTransaction(haproxyVersion string) (*string, error) {
+	transactionId := "tx" + haproxyVersion
+	return &transactionId, nil
+}
+
+func (n *fakeClusterNode) CommitTransaction(transactionId string) error {
+	n.record("commit " + transactionId)
+	if n.failAt == "commit" || n.failAt == "delete" {
+		return errors.New("version mismatch")
+	}
+	return nil
+}
+
+func (n *fakeClusterNode) DeleteTransaction(transactionId string) error {
+	n.record("delete " + transactionId)
+	if n.failAt == "delete" {
+		return errors.New("transaction not found")
+	}
+	return nil
+}
+
+func (n *fakeClusterNode) PushRawConfiguration(version int, raw string) error {
+	n.record("push " + strings.TrimSpace(raw))
+	return nil
+}
+
+func TestClusterTransaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   ClusterPolicy
+		failAt   map[string]string
+		failed   []string // the nodes with an error
+		restored []string // the nodes rolled back
+		calls    map[string][]string
+	}{
+		{
+			"all nodes commit",
+			ClusterAllOrNothing,
+			nil,
+			[]string{}, []string{},
+			map[string][]string{"a": {"commit tx4"}, "b": {"commit tx4"}, "c": {"commit tx4"}},
+		},
+		{
+			"a failed prepare discards every transaction",
+			ClusterAllOrNothing,
+			map[string]string{"b": "prepare"},
+			[]string{"b"}, []string{},
+			map[string][]string{"a": {"delete tx4"}, "b": {"delete tx4"}, "c": {"delete tx4"}},
+		},
+		{
+			"an unreachable node commits nothing",
+			ClusterAllOrNothing,
+			map[string]string{"c": "snapshot"},
+			[]string{"c"}, []string{},
+			map[string][]string{"a": {"delete tx4"}, "b": {"delete tx4"}, "c": nil},
+		},
+		{
+			"a failed commit restores the committed nodes",
+			ClusterAllOrNothing,
+			map[string]string{"b": "commit"},
+			[]string{"b"}, []string{"a", "c"},
+			map[string][]string{
+				"a": {"commit tx4", "push backend a"},
+				"b": {"commit tx4", "delete tx4"},
+				"c": {"commit tx4", "push backend c"},
+			},
+		},
+		{
+			"best effort keeps the other nodes",
+			ClusterBestEffort,
+			map[string]string{"b": "commit"},
+			[]string{"b"}, []string{},
+			map[string][]string{"a": {"commit tx4"}, "b": {"commit tx4", "delete tx4"}, "c": {"commit tx4"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakes := map[string]*fakeClusterNode{}
+			nodes := map[string]IHaproxyClient{}
+			for _, name := range []string{"a", "b", "c"} {
+				fakes[name] = &fakeClusterNode{failAt: test.failAt[name], raw: "backend " + name}
+				nodes[name] = fakes[name]
+			}
+			cluster := NewHaproxyCluster(nodes, test.policy)
+			results, err := cluster.Transaction(func(client IHaproxyClient, transactionId string) error {
+				if client.(*fakeClusterNode).failAt == "prepare" {
+					return errors.New("invalid backend")
+				}
+				return nil
+			})
+			if (err != nil) != (len(test.failed) > 0) {
+				t.Fatalf("error %v with %v failing", err, test.failed)
+			}
+			failed, restored := []string{}, []string{}
+			for _, result := range results {
+				if result.Err != nil {
+					failed = append(failed, result.Node)
+				}
+				if result.RolledBack {
+					restored = append(restored, result.Node)
+				}
+				if result.RollbackErr != nil {
+					t.Fatalf("node %s rollback: %v", result.Node, result.RollbackErr)
+				}
+			}
+			if !reflect.DeepEqual(failed, test.failed) || !reflect.DeepEqual(restored, test.restored) {
+				t.Fatalf("failed %v restored %v, want %v and %v", failed, restored, test.failed, test.restored)
+			}
+			for name, want := range test.calls {
+				calls := fakes[name].calls
+				sort.Strings(calls)
+				if !reflect.DeepEqual(calls, want) {
+					t.Fatalf("node %s calls %q, want %q", name, calls, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterTransactionCleanupError(t *testing.T) {
+	for _, policy := range []ClusterPolicy{ClusterAllOrNothing, ClusterBestEffort} {
+		t.Run(string(policy), func(t *testing.T) {
+			nodes := map[string]IHaproxyClient{"a": &fakeClusterNode{raw: "backend a"}, "b": &fakeClusterNode{failAt: "delete", raw: "backend b"}}
+			results, err := NewHaproxyCluster(nodes, policy).Transaction(func(client IHaproxyClient, transactionId string) error {
+				return nil
+			})
+			if err == nil {
+				t.Fatal("no error for a failed commit")
+			}
+			for _, result := range results {
+				if (result.CleanupErr != nil) != (result.Node == "b") {
+					t.Fatalf("node %s cleanup error %v", result.Node, result.CleanupErr)
+				}
+			}
+			data, err := json.Marshal(results[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), `"cleanup_error":"transaction not found"`) {
+				t.Fatalf("result %s without the cleanup error", data)
+			}
+		})
+	}
+}
+
+func TestPrepareTransactionCleanupError(t *testing.T) {
+	node := &fakeClusterNode{failAt: "delete"}
+	err := RunTransaction(node, func(client IHaproxyClient, transactionId string) error {
+		return errors.New("invalid backend")
+	})
+	if err == nil || err.Error() != "invalid backend (the transaction tx4 is left: transaction not found)" {
+		t.Fatalf("error %v", err)
+	}
+}
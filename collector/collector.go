@@ -0,0 +1,209 @@
+// Package collector exposes the haproxy native stats and the dataplane api calls of a client as
+// prometheus metrics, apart from the client package so that it does not depend on client_golang.
+package collector
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	haproxy "github.com/Allan-Nava/haproxy-dataplane-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// a native stats counter or gauge exposed for the stat types listing it
+type statMetric struct {
+	name      string
+	help      string
+	valueType prometheus.ValueType
+	types     []string
+	value     func(v *haproxy.HaproxyNativeStatValues) float64
+}
+
+var (
+	allStatTypes     = []string{"frontend", "backend", "server"}
+	proxyStatTypes   = []string{"frontend", "backend"}
+	backendStatTypes = []string{"backend", "server"}
+)
+
+var statMetrics = []statMetric{
+	{"bytes_in_total", "Bytes received.", prometheus.CounterValue, allStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Bin) }},
+	{"bytes_out_total", "Bytes sent.", prometheus.CounterValue, allStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Bout) }},
+	{"sessions_total", "Sessions handled.", prometheus.CounterValue, allStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Stot) }},
+	{"current_sessions", "Current sessions.", prometheus.GaugeValue, allStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Scur) }},
+	{"max_sessions", "Maximum observed sessions.", prometheus.GaugeValue, allStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Smax) }},
+	{"limit_sessions", "Configured session limit.", prometheus.GaugeValue, allStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Slim) }},
+	{"current_session_rate", "Sessions per second over the last second.", prometheus.GaugeValue, allStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Rate) }},
+	{"http_requests_total", "HTTP requests received.", prometheus.CounterValue, []string{"frontend"}, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.ReqTotal) }},
+	{"current_request_rate", "HTTP requests per second over the last second.", prometheus.GaugeValue, []string{"frontend"}, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.ReqRate) }},
+	{"request_errors_total", "Request errors.", prometheus.CounterValue, []string{"frontend"}, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Ereq) }},
+	{"requests_denied_total", "Requests denied by security rules.", prometheus.CounterValue, proxyStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Dreq) }},
+	{"responses_denied_total", "Responses denied by security rules.", prometheus.CounterValue, allStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Dresp) }},
+	{"connection_errors_total", "Connection errors towards the servers.", prometheus.CounterValue, backendStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Econ) }},
+	{"response_errors_total", "Response errors from the servers.", prometheus.CounterValue, backendStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Eresp) }},
+	{"current_queue", "Requests waiting in queue.", prometheus.GaugeValue, backendStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Qcur) }},
+	{"weight", "Effective weight.", prometheus.GaugeValue, backendStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Weight) }},
+	{"active_servers", "Active servers.", prometheus.GaugeValue, []string{"backend"}, func(v *haproxy.HaproxyNativeStatValues) float64 { return float64(v.Act) }},
+	{"up", "Current status, 1 when UP (or OPEN).", prometheus.GaugeValue, backendStatTypes, func(v *haproxy.HaproxyNativeStatValues) float64 {
+		if haproxy.IsServerUp(v.Status) {
+			return 1
+		}
+		return 0
+	}},
+}
+
+var httpResponseCodes = []struct {
+	code  string
+	value func(v *haproxy.HaproxyNativeStatValues) int
+}{
+	{"1xx", func(v *haproxy.HaproxyNativeStatValues) int { return v.Hrsp1Xx }},
+	{"2xx", func(v *haproxy.HaproxyNativeStatValues) int { return v.Hrsp2Xx }},
+	{"3xx", func(v *haproxy.HaproxyNativeStatValues) int { return v.Hrsp3Xx }},
+	{"4xx", func(v *haproxy.HaproxyNativeStatValues) int { return v.Hrsp4Xx }},
+	{"5xx", func(v *haproxy.HaproxyNativeStatValues) int { return v.Hrsp5Xx }},
+	{"other", func(v *haproxy.HaproxyNativeStatValues) int { return v.HrspOther }},
+}
+
+// a prometheus.Collector scraping the native stats on each collect, the metrics are named
+// <namespace>_<frontend|backend|server>_<metric> and labelled by proxy (and server), eg:
+// haproxy_backend_http_responses_total{proxy="app",code="5xx"}.
+//
+// It also exposes the latency and errors of the calls made by the client to the dataplane api, those
+// are only observed for the clients created with haproxy.NewHaproxyClient.
+type HaproxyCollector struct {
+	client haproxy.IHaproxyClient
+
+	descs         map[string]map[string]*prometheus.Desc // stat type -> metric name -> desc
+	responsesDesc map[string]*prometheus.Desc
+	upDesc        *prometheus.Desc
+	durationDesc  *prometheus.Desc
+	api           *apiMetrics
+	stopObserving func()
+}
+
+// the latency and errors of the dataplane api calls observed by a collector
+type apiMetrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+func newApiMetrics(namespace string) *apiMetrics {
+	return &apiMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "dataplane_api_request_duration_seconds",
+			Help:      "Latency of the dataplane api calls made by the client.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dataplane_api_errors_total",
+			Help:      "Dataplane api calls which failed, with a transport error, an unreadable response or a non 2xx status.",
+		}, []string{"method"}),
+	}
+}
+
+// a call is counted once as an error, whether its status or its response failed
+func (m *apiMetrics) observe(method string, status int, duration time.Duration, err error) {
+	if status != 0 {
+		m.duration.WithLabelValues(method, strconv.Itoa(status)).Observe(duration.Seconds())
+	}
+	if err != nil || status >= 400 {
+		m.errors.WithLabelValues(method).Inc()
+	}
+}
+
+// create a collector, namespace defaults to "haproxy". The collector observes the calls of the client
+// until Close is called.
+func NewHaproxyCollector(client haproxy.IHaproxyClient, namespace string) *HaproxyCollector {
+	if namespace == "" {
+		namespace = "haproxy"
+	}
+	c := HaproxyCollector{
+		client:        client,
+		descs:         map[string]map[string]*prometheus.Desc{},
+		responsesDesc: map[string]*prometheus.Desc{},
+		upDesc:        prometheus.NewDesc(namespace+"_dataplane_up", "Whether the last native stats scrape succeeded.", nil, nil),
+		durationDesc:  prometheus.NewDesc(namespace+"_dataplane_scrape_duration_seconds", "Duration of the native stats scrape.", nil, nil),
+		api:           newApiMetrics(namespace),
+	}
+	c.stopObserving, _ = haproxy.ObserveRequests(client, c.api.observe)
+	for _, statType := range allStatTypes {
+		labels := []string{"proxy"}
+		if statType == "server" {
+			labels = []string{"proxy", "server"}
+		}
+		c.descs[statType] = map[string]*prometheus.Desc{}
+		for _, metric := range statMetrics {
+			for _, t := range metric.types {
+				if t == statType {
+					c.descs[statType][metric.name] = prometheus.NewDesc(prometheus.BuildFQName(namespace, statType, metric.name), metric.help, labels, nil)
+				}
+			}
+		}
+		c.responsesDesc[statType] = prometheus.NewDesc(prometheus.BuildFQName(namespace, statType, "http_responses_total"), "HTTP responses by status class.", append(labels, "code"), nil)
+	}
+	return &c
+}
+
+// stop observing the dataplane api calls of the client
+func (c *HaproxyCollector) Close() {
+	c.stopObserving()
+}
+
+func (c *HaproxyCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, descs := range c.descs {
+		for _, desc := range descs {
+			ch <- desc
+		}
+	}
+	for _, desc := range c.responsesDesc {
+		ch <- desc
+	}
+	ch <- c.upDesc
+	ch <- c.durationDesc
+	c.api.duration.Describe(ch)
+	c.api.errors.Describe(ch)
+}
+
+func (c *HaproxyCollector) Collect(ch chan<- prometheus.Metric) {
+	start := time.Now()
+	stats, err := c.client.GetStats()
+	ch <- prometheus.MustNewConstMetric(c.durationDesc, prometheus.GaugeValue, time.Since(start).Seconds())
+	c.api.duration.Collect(ch)
+	c.api.errors.Collect(ch)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, 1)
+
+	for _, stat := range stats.Merged() {
+		labels := []string{stat.Name}
+		if stat.Type == "server" {
+			labels = []string{stat.BackendName, stat.Name}
+		}
+		descs, known := c.descs[stat.Type]
+		if !known {
+			continue
+		}
+		for _, metric := range statMetrics {
+			if desc, exist := descs[metric.name]; exist {
+				ch <- prometheus.MustNewConstMetric(desc, metric.valueType, metric.value(&stat.Stats), labels...)
+			}
+		}
+		if stat.Type != "server" || stat.Stats.Mode == "http" {
+			for _, code := range httpResponseCodes {
+				ch <- prometheus.MustNewConstMetric(c.responsesDesc[stat.Type], prometheus.CounterValue, float64(code.value(&stat.Stats)), append(labels, code.code)...)
+			}
+		}
+	}
+}
+
+// an http handler serving the metrics of a collector which is never closed, eg: http.Handle("/metrics", collector.MetricsHandler(client, ""))
+func MetricsHandler(client haproxy.IHaproxyClient, namespace string) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewHaproxyCollector(client, namespace))
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	haproxy "github.com/Allan-Nava/haproxy-dataplane-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// a client answering GetStats only, with an error when it has no stats
+type fakeStatsClient struct {
+	haproxy.IHaproxyClient
+	stats *haproxy.HaproxyStats
+}
+
+func (c *fakeStatsClient) GetStats() (*haproxy.HaproxyStats, error) {
+	if c.stats == nil {
+		return nil, errors.New("connection refused")
+	}
+	return c.stats, nil
+}
+
+func TestHaproxyCollector(t *testing.T) {
+	stats := haproxy.HaproxyStats{
+		{Stats: []haproxy.HaproxyNativeStat{
+			{Type: "frontend", Name: "www", Stats: haproxy.HaproxyNativeStatValues{Status: "OPEN", ReqTotal: 10, Hrsp2Xx: 9, Hrsp5Xx: 1, Scur: 2}},
+			{Type: "server", BackendName: "app", Name: "app1", Stats: haproxy.HaproxyNativeStatValues{Status: "UP", Mode: "http", Stot: 4, Hrsp2Xx: 4}},
+			{Type: "server", BackendName: "db", Name: "db1", Stats: haproxy.HaproxyNativeStatValues{Status: "DOWN", Mode: "tcp", Stot: 1}},
+		}},
+		// the second process is summed with the first one
+		{Stats: []haproxy.HaproxyNativeStat{
+			{Type: "frontend", Name: "www", Stats: haproxy.HaproxyNativeStatValues{Status: "OPEN", ReqTotal: 5, Hrsp2Xx: 5, Scur: 1}},
+		}},
+	}
+	tests := []struct {
+		name     string
+		stats    *haproxy.HaproxyStats
+		metrics  []string
+		expected string
+	}{
+		{
+			"frontend counters summed over the processes",
+			&stats,
+			[]string{"test_frontend_http_requests_total", "test_frontend_current_sessions", "test_frontend_http_responses_total"},
+			`
+# HELP test_frontend_current_sessions Current sessions.
+# TYPE test_frontend_current_sessions gauge
+test_frontend_current_sessions{proxy="www"} 3
+# HELP test_frontend_http_requests_total HTTP requests received.
+# TYPE test_frontend_http_requests_total counter
+test_frontend_http_requests_total{proxy="www"} 15
+# HELP test_frontend_http_responses_total HTTP responses by status class.
+# TYPE test_frontend_http_responses_total counter
+test_frontend_http_responses_total{code="1xx",proxy="www"} 0
+test_frontend_http_responses_total{code="2xx",proxy="www"} 14
+test_frontend_http_responses_total{code="3xx",proxy="www"} 0
+test_frontend_http_responses_total{code="4xx",proxy="www"} 0
+test_frontend_http_responses_total{code="5xx",proxy="www"} 1
+test_frontend_http_responses_total{code="other",proxy="www"} 0
+`,
+		},
+		{
+			"http responses of the http servers only",
+			&stats,
+			[]string{"test_server_up", "test_server_sessions_total", "test_server_http_responses_total", "test_dataplane_up"},
+			`
+# HELP test_dataplane_up Whether the last native stats scrape succeeded.
+# TYPE test_dataplane_up gauge
+test_dataplane_up 1
+# HELP test_server_http_responses_total HTTP responses by status class.
+# TYPE test_server_http_responses_total counter
+test_server_http_responses_total{code="1xx",proxy="app",server="app1"} 0
+test_server_http_responses_total{code="2xx",proxy="app",server="app1"} 4
+test_server_http_responses_total{code="3xx",proxy="app",server="app1"} 0
+test_server_http_responses_total{code="4xx",proxy="app",server="app1"} 0
+test_server_http_responses_total{code="5xx",proxy="app",server="app1"} 0
+test_server_http_responses_total{code="other",proxy="app",server="app1"} 0
+# HELP test_server_sessions_total Sessions handled.
+# TYPE test_server_sessions_total counter
+test_server_sessions_total{proxy="app",server="app1"} 4
+test_server_sessions_total{proxy="db",server="db1"} 1
+# HELP test_server_up Current status, 1 when UP (or OPEN).
+# TYPE test_server_up gauge
+test_server_up{proxy="app",server="app1"} 1
+test_server_up{proxy="db",server="db1"} 0
+`,
+		},
+		{
+			"failed scrape",
+			nil,
+			[]string{"test_dataplane_up", "test_frontend_http_requests_total"},
+			`
+# HELP test_dataplane_up Whether the last native stats scrape succeeded.
+# TYPE test_dataplane_up gauge
+test_dataplane_up 0
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			collector := NewHaproxyCollector(&fakeStatsClient{stats: test.stats}, "test")
+			if err := testutil.CollectAndCompare(collector, strings.NewReader(test.expected), test.metrics...); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestHaproxyCollectorApiMetrics(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		closed    bool
+		errors    float64
+		durations int // the observed latencies, one per status
+	}{
+		{"success", http.StatusOK, `{"_version":1,"data":[]}`, false, 0, 1},
+		{"error status", http.StatusInternalServerError, `{"code":500,"message":"configuration file is locked"}`, false, 1, 1},
+		{"error status with an unreadable body", http.StatusInternalServerError, `{"code":`, false, 1, 1},
+		{"success with an unreadable body", http.StatusOK, `{"data":`, false, 1, 1},
+		{"closed collector", http.StatusInternalServerError, `{"code":500,"message":"configuration file is locked"}`, true, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if r.URL.Path == "/v2/" {
+					w.Write([]byte(`[]`))
+					return
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+			client, err := haproxy.NewHaproxyClient(server.URL, "admin", "admin", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			collector := NewHaproxyCollector(client, "test")
+			if test.closed {
+				collector.Close()
+			}
+			client.GetBackends()
+			if count := testutil.ToFloat64(collector.api.errors.WithLabelValues("GET")); count != test.errors {
+				t.Fatalf("%v errors, want %v", count, test.errors)
+			}
+			if durations := testutil.CollectAndCount(collector.api.duration); durations != test.durations {
+				t.Fatalf("%d latencies, want %d", durations, test.durations)
+			}
+		})
+	}
+}
+
+func TestHaproxyCollectorsOfTheSameClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"_version":1,"data":[]}`))
+	}))
+	defer server.Close()
+	client, err := haproxy.NewHaproxyClient(server.URL, "admin", "admin", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := NewHaproxyCollector(client, "test"), NewHaproxyCollector(client, "test")
+	defer first.Close()
+	defer second.Close()
+	// each collector owns its metrics, they are registered on their own registry
+	for _, collector := range []*HaproxyCollector{first, second} {
+		if err := prometheus.NewRegistry().Register(collector); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.GetBackends()
+	for _, collector := range []*HaproxyCollector{first, second} {
+		if count := testutil.CollectAndCount(collector.api.duration); count != 1 {
+			t.Fatalf("%d latencies, want 1", count)
+		}
+	}
+}
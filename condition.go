@@ -0,0 +1,494 @@
+package haproxy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// the cond of the rules
+const (
+	CondIf     = "if"
+	CondUnless = "unless"
+)
+
+// an acl criterion: a sample fetch, its flags and the values matched, eg: hdr(host) -i example.com
+type AclCriterion struct {
+	Fetch  string   `json:"fetch"`
+	Flags  []string `json:"flags,omitempty"`
+	Values []string `json:"values,omitempty"`
+}
+
+func Criterion(fetch string, values ...string) AclCriterion {
+	return AclCriterion{Fetch: fetch, Values: values}
+}
+
+func Hdr(name string, values ...string) AclCriterion {
+	return Criterion("hdr("+name+")", values...)
+}
+
+func HdrBeg(name string, values ...string) AclCriterion {
+	return Criterion("hdr_beg("+name+")", values...)
+}
+
+func HdrEnd(name string, values ...string) AclCriterion {
+	return Criterion("hdr_end("+name+")", values...)
+}
+
+func HdrSub(name string, values ...string) AclCriterion {
+	return Criterion("hdr_sub("+name+")", values...)
+}
+
+func HdrReg(name string, patterns ...string) AclCriterion {
+	return Criterion("hdr_reg("+name+")", patterns...)
+}
+
+func HdrDom(name string, values ...string) AclCriterion {
+	return Criterion("hdr_dom("+name+")", values...)
+}
+
+// hdr(host) -i, the host header compared case insensitively
+func Host(hosts ...string) AclCriterion {
+	return Hdr("host", hosts...).IgnoreCase()
+}
+
+func Path(paths ...string) AclCriterion {
+	return Criterion("path", paths...)
+}
+
+func PathBeg(prefixes ...string) AclCriterion {
+	return Criterion("path_beg", prefixes...)
+}
+
+func PathEnd(suffixes ...string) AclCriterion {
+	return Criterion("path_end", suffixes...)
+}
+
+func PathSub(values ...string) AclCriterion {
+	return Criterion("path_sub", values...)
+}
+
+func PathDir(values ...string) AclCriterion {
+	return Criterion("path_dir", values...)
+}
+
+func PathReg(patterns ...string) AclCriterion {
+	return Criterion("path_reg", patterns...)
+}
+
+func UrlParam(name string, values ...string) AclCriterion {
+	return Criterion("url_param("+name+")", values...)
+}
+
+func Method(methods ...string) AclCriterion {
+	return Criterion("method", methods...)
+}
+
+// source addresses or networks, eg: Src("10.0.0.0/8", "192.168.0.1")
+func Src(addresses ...string) AclCriterion {
+	return Criterion("src", addresses...)
+}
+
+func Dst(addresses ...string) AclCriterion {
+	return Criterion("dst", addresses...)
+}
+
+func DstPort(ports ...string) AclCriterion {
+	return Criterion("dst_port", ports...)
+}
+
+// true when the connection was made over ssl
+func SslFc() AclCriterion {
+	return Criterion("ssl_fc")
+}
+
+func SslFcSni(names ...string) AclCriterion {
+	return Criterion("ssl_fc_sni", names...)
+}
+
+// a copy of the criterion matching case insensitively (-i)
+func (c AclCriterion) IgnoreCase() AclCriterion {
+	return c.withFlags("-i")
+}
+
+// a copy of the criterion using another match method (-m beg, -m reg, ...)
+func (c AclCriterion) Match(method string) AclCriterion {
+	return c.withFlags("-m", method)
+}
+
+func (c AclCriterion) withFlags(flags ...string) AclCriterion {
+	c.Flags = append(append([]string{}, c.Flags...), flags...)
+	return c
+}
+
+// the flags and values, as the value of an acl. The values containing spaces are quoted.
+func (c AclCriterion) Value() string {
+	parts := append([]string{}, c.Flags...)
+	for _, value := range c.Values {
+		switch {
+		case value == "" || strings.ContainsAny(value, " \t'"):
+			value = `"` + value + `"`
+		case strings.Contains(value, `"`):
+			value = "'" + value + "'"
+		}
+		parts = append(parts, value)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c AclCriterion) String() string {
+	return strings.TrimSpace(c.Fetch + " " + c.Value())
+}
+
+// a named acl with this criterion
+func (c AclCriterion) Acl(name string) HaproxyAddAcl {
+	return HaproxyAddAcl{AclName: name, Criterion: c.Fetch, Value: c.Value()}
+}
+
+// validate the fetch of the criterion
+func (c AclCriterion) Validate() error {
+	return validateFetch(c.Fetch)
+}
+
+// a term of a condition: a named acl or an anonymous one between braces, possibly negated
+type ConditionTerm struct {
+	Negated   bool          `json:"negated,omitempty"`
+	Acl       string        `json:"acl,omitempty"`
+	Anonymous *AclCriterion `json:"anonymous,omitempty"`
+}
+
+func (t ConditionTerm) String() string {
+	prefix := ""
+	if t.Negated {
+		prefix = "!"
+	}
+	if t.Anonymous != nil {
+		return prefix + "{ " + t.Anonymous.String() + " }"
+	}
+	return prefix + t.Acl
+}
+
+// a condition in the form haproxy evaluates it: alternatives separated by || of terms which must
+// all match, eg: "is_api !is_internal || { src 10.0.0.0/8 }" is [[is_api !is_internal] [{ src 10.0.0.0/8 }]].
+//
+// Haproxy conditions have no parentheses, And, Or and Not distribute their operands to keep this form.
+type Condition struct {
+	Or [][]ConditionTerm `json:"or"`
+}
+
+// a condition on a named acl
+func AclRef(name string) Condition {
+	return Condition{Or: [][]ConditionTerm{{{Acl: name}}}}
+}
+
+// a condition on an anonymous acl
+func Anonymous(criterion AclCriterion) Condition {
+	return Condition{Or: [][]ConditionTerm{{{Anonymous: &criterion}}}}
+}
+
+// all the conditions must match
+func And(conditions ...Condition) Condition {
+	result := Condition{Or: [][]ConditionTerm{{}}}
+	for _, condition := range conditions {
+		product := [][]ConditionTerm{}
+		for _, left := range result.Or {
+			for _, right := range condition.Or {
+				product = append(product, append(append([]ConditionTerm{}, left...), right...))
+			}
+		}
+		result.Or = product
+	}
+	return result
+}
+
+// one of the conditions must match
+func Or(conditions ...Condition) Condition {
+	result := Condition{Or: [][]ConditionTerm{}}
+	for _, condition := range conditions {
+		result.Or = append(result.Or, condition.Or...)
+	}
+	return result
+}
+
+// the condition must not match
+func Not(condition Condition) Condition {
+	// !(a b || c) is (!a || !b) !c
+	alternatives := []Condition{}
+	for _, terms := range condition.Or {
+		negated := Condition{Or: [][]ConditionTerm{}}
+		for _, term := range terms {
+			term.Negated = !term.Negated
+			negated.Or = append(negated.Or, []ConditionTerm{term})
+		}
+		alternatives = append(alternatives, negated)
+	}
+	return And(alternatives...)
+}
+
+// the cond_test of the condition
+func (c Condition) String() string {
+	alternatives := []string{}
+	for _, terms := range c.Or {
+		parts := []string{}
+		for _, term := range terms {
+			parts = append(parts, term.String())
+		}
+		alternatives = append(alternatives, strings.Join(parts, " "))
+	}
+	return strings.Join(alternatives, " || ")
+}
+
+// the cond and cond_test of a rule applied if the condition matches, eg: rule.Cond, rule.CondTest = condition.If()
+func (c Condition) If() (string, string) {
+	return CondIf, c.String()
+}
+
+// the cond and cond_test of a rule applied unless the condition matches
+func (c Condition) Unless() (string, string) {
+	return CondUnless, c.String()
+}
+
+// the named acls referenced by the condition, in order of appearance
+func (c Condition) AclNames() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, terms := range c.Or {
+		for _, term := range terms {
+			if term.Anonymous == nil && !seen[term.Acl] {
+				seen[term.Acl] = true
+				names = append(names, term.Acl)
+			}
+		}
+	}
+	return names
+}
+
+var aclNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// parse and validate a cond_test, eg: ParseCondition("is_api !is_internal || { src 10.0.0.0/8 }").
+// Only the syntax of the fetches of the anonymous acls is checked, see KnownFetch.
+func ParseCondition(condTest string) (*Condition, error) {
+	tokens, err := conditionTokens(condTest)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	condition := Condition{Or: [][]ConditionTerm{{}}}
+	negated := false
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		current := &condition.Or[len(condition.Or)-1]
+		switch {
+		case token == "||" || token == "or":
+			if negated || len(*current) == 0 {
+				return nil, fmt.Errorf("condition %q: %s without a term before it", condTest, token)
+			}
+			condition.Or = append(condition.Or, []ConditionTerm{})
+		case token == "!":
+			if negated {
+				return nil, fmt.Errorf("condition %q: double negation", condTest)
+			}
+			negated = true
+		case token == "{":
+			end := i + 1
+			for end < len(tokens) && tokens[end] != "}" {
+				if tokens[end] == "{" {
+					return nil, fmt.Errorf("condition %q: nested braces", condTest)
+				}
+				end++
+			}
+			if end == len(tokens) {
+				return nil, fmt.Errorf("condition %q: unclosed brace", condTest)
+			}
+			criterion, err := parseCriterion(tokens[i+1 : end])
+			if err != nil {
+				return nil, fmt.Errorf("condition %q: %w", condTest, err)
+			}
+			*current = append(*current, ConditionTerm{Negated: negated, Anonymous: criterion})
+			negated = false
+			i = end
+		case token == "}":
+			return nil, fmt.Errorf("condition %q: unexpected }", condTest)
+		case token == "&&" || token == "and":
+			return nil, fmt.Errorf("condition %q: %s is not an operator, the terms separated by spaces must all match", condTest, token)
+		case token == CondIf || token == CondUnless:
+			return nil, fmt.Errorf("condition %q: %s belongs to cond, not cond_test", condTest, token)
+		default:
+			name := token
+			if strings.HasPrefix(name, "!") {
+				if negated {
+					return nil, fmt.Errorf("condition %q: double negation", condTest)
+				}
+				name, negated = name[1:], true
+			}
+			if !aclNamePattern.MatchString(name) {
+				return nil, fmt.Errorf("condition %q: invalid acl name %q", condTest, name)
+			}
+			*current = append(*current, ConditionTerm{Negated: negated, Acl: name})
+			negated = false
+		}
+	}
+	if negated || len(condition.Or[len(condition.Or)-1]) == 0 {
+		return nil, fmt.Errorf("condition %q: missing term at the end", condTest)
+	}
+	return &condition, nil
+}
+
+// validate the cond and cond_test of a rule, both are empty for an unconditional rule
+func ValidateCondition(cond string, condTest string) error {
+	switch {
+	case cond == "" && condTest == "":
+		return nil
+	case cond != CondIf && cond != CondUnless:
+		return fmt.Errorf("invalid cond %q, expected %s or %s", cond, CondIf, CondUnless)
+	}
+	_, err := ParseCondition(condTest)
+	return err
+}
+
+// split a condition on spaces, quoted strings are kept together. Braces must be surrounded by
+// spaces as haproxy requires, a "!" may be glued to the opening one (a glued closing brace shows
+// up as an unclosed brace since values, like regexes, may end with one).
+func conditionTokens(condTest string) ([]string, error) {
+	tokens := []string{}
+	token := strings.Builder{}
+	quote := rune(0)
+	quoted := false
+	flush := func() {
+		if token.Len() > 0 || quoted {
+			tokens = append(tokens, token.String())
+		}
+		token.Reset()
+		quoted = false
+	}
+	for _, char := range condTest {
+		switch {
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote != 0:
+			token.WriteRune(char)
+		case char == '"' || char == '\'':
+			quote, quoted = char, true
+		case char == ' ' || char == '\t':
+			flush()
+		default:
+			token.WriteRune(char)
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("condition %q: unclosed quote", condTest)
+	}
+	flush()
+	result := []string{}
+	for _, token := range tokens {
+		switch {
+		case token == "!{":
+			result = append(result, "!", "{")
+		case strings.HasPrefix(token, "{") && token != "{":
+			return nil, fmt.Errorf("condition %q: braces must be separated by spaces in %q", condTest, token)
+		default:
+			result = append(result, token)
+		}
+	}
+	return result, nil
+}
+
+// the flags taking an argument
+var criterionFlagArguments = map[string]bool{"-m": true, "-f": true, "-u": true, "-M": false, "-i": false, "-n": false}
+
+func parseCriterion(tokens []string) (*AclCriterion, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty anonymous acl")
+	}
+	criterion := AclCriterion{Fetch: tokens[0]}
+	if err := validateFetch(criterion.Fetch); err != nil {
+		return nil, err
+	}
+	i := 1
+	for ; i < len(tokens) && strings.HasPrefix(tokens[i], "-") && tokens[i] != "-"; i++ {
+		if tokens[i] == "--" {
+			criterion.Flags = append(criterion.Flags, tokens[i])
+			i++
+			break
+		}
+		takesArgument, known := criterionFlagArguments[tokens[i]]
+		if !known {
+			break
+		}
+		criterion.Flags = append(criterion.Flags, tokens[i])
+		if takesArgument {
+			if i+1 == len(tokens) {
+				return nil, fmt.Errorf("flag %s of %s without argument", tokens[i], criterion.Fetch)
+			}
+			i++
+			criterion.Flags = append(criterion.Flags, tokens[i])
+		}
+	}
+	criterion.Values = append(criterion.Values, tokens[i:]...)
+	return &criterion, nil
+}
+
+// the sample fetches known to the linter, the other ones are only reported as warnings (see LintUnknownFetch)
+// since every haproxy version and lua script adds more, the missing ones can be added here
+var KnownFetches = map[string]bool{}
+
+func init() {
+	for _, fetch := range strings.Fields(`
+		always_false always_true avg_queue base base32 base32+src base_beg base_dir base_dom base_end base_len base_reg base_sub
+		be_conn be_id be_name be_sess_rate capture.req.hdr capture.req.method capture.req.uri capture.res.hdr connslots
+		cook cook_beg cook_cnt cook_dir cook_dom cook_end cook_len cook_reg cook_sub cook_val dst dst_conn
+		dst_port env fc_http_major fc_rcvd_proxy fe_conn fe_id fe_name fe_sess_rate hdr hdr_beg hdr_cnt
+		hdr_dir hdr_dom hdr_end hdr_ip hdr_len hdr_reg hdr_sub hdr_val http_auth http_auth_group http_first_req
+		method nbproc nbsrv path path_beg path_dir path_dom path_end path_len path_reg path_sub query queue
+		rand rdp_cookie req.body req.body_len req.body_param req.body_size req.cook req.cook_cnt req.fhdr
+		req.fhdr_cnt req.hdr req.hdr_cnt req.hdr_ip req.hdr_val req.hdrs req.len req.payload req.payload_lv
+		req.proto_http req.rdp_cookie req.ssl_alpn req.ssl_ec_ext req.ssl_hello_type req.ssl_sni req.ssl_st_ext
+		req.ssl_ver req.ver req_len req_proto_http req_ssl_hello_type req_ssl_sni req_ssl_ver req_ver
+		res.comp res.cook res.fhdr res.hdr res.hdr_cnt res.len res.payload res.ssl_hello_type res.ver
+		sc_http_req_rate scook shdr src src_conn_cnt src_conn_cur src_conn_rate src_get_gpc0 src_get_gpt0
+		src_http_err_rate src_http_req_cnt src_http_req_rate src_is_local src_port srv_conn srv_id srv_is_up
+		srv_queue ssl_bc ssl_c_ca_err ssl_c_err ssl_c_s_dn ssl_c_i_dn ssl_c_sha1 ssl_c_used ssl_c_verify
+		ssl_fc ssl_fc_alpn ssl_fc_cipher ssl_fc_has_crt ssl_fc_has_early ssl_fc_has_sni ssl_fc_npn
+		ssl_fc_protocol ssl_fc_session_id ssl_fc_sni ssl_fc_sni_end ssl_fc_sni_reg stopping status txn.status unique-id url
+		url_beg url_dir url_dom url_end url_ip url_len url_param url_port url_reg url_sub urlp urlp_val
+		var wait_end`) {
+		KnownFetches[fetch] = true
+	}
+}
+
+// the stick counter (sc0_*, sc1_*, sc2_*, sc_*), table (table_*) and lua (lua.*) fetches are known by prefix
+var prefixedFetch = regexp.MustCompile(`^(sc[0-9]*_[a-z0-9_]+|table_[a-z0-9_]+|lua\..+)$`)
+
+// the name of a fetch, eg: base32+src, req.hdr, unique-id or lua.my_fetch
+var fetchNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.+-]*$`)
+
+// tell if the name of the fetch (without its arguments and converters) is in KnownFetches or has a known prefix
+func KnownFetch(fetch string) bool {
+	name := fetchName(fetch)
+	return KnownFetches[name] || prefixedFetch.MatchString(name)
+}
+
+func fetchName(fetch string) string {
+	if end := strings.IndexAny(fetch, "(,"); end >= 0 {
+		return fetch[:end]
+	}
+	return fetch
+}
+
+// validate the syntax of the fetch of a criterion, the fetch itself, its arguments (between parentheses)
+// and converters (after commas) are left to haproxy
+func validateFetch(fetch string) error {
+	name := fetchName(fetch)
+	if strings.Count(fetch, "(") != strings.Count(fetch, ")") {
+		return fmt.Errorf("unbalanced parentheses in %q", fetch)
+	}
+	if name == "" {
+		return fmt.Errorf("empty fetch in %q", fetch)
+	}
+	if !fetchNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid fetch %q", name)
+	}
+	return nil
+}
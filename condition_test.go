@@ -0,0 +1,140 @@
+package haproxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		condTest string
+		want     string // the parsed condition printed back, empty for an error
+		acls     []string
+	}{
+		{"is_api", "is_api", []string{"is_api"}},
+		{"is_api !is_internal || { src 10.0.0.0/8 }", "is_api !is_internal || { src 10.0.0.0/8 }", []string{"is_api", "is_internal"}},
+		{"is_api or ! is_static", "is_api || !is_static", []string{"is_api", "is_static"}},
+		{"!{ path_beg -i /api /v2 }", "!{ path_beg -i /api /v2 }", []string{}},
+		{`{ hdr(host) -m str "a b" }`, `{ hdr(host) -m str "a b" }`, []string{}},
+		{"{ req.hdr(x-id),lower -m found }", "{ req.hdr(x-id),lower -m found }", []string{}},
+		{"HTTP_2.0 is_api", "HTTP_2.0 is_api", []string{"HTTP_2.0", "is_api"}},
+		// only the syntax of the fetches is checked
+		{"{ be_name app }", "{ be_name app }", []string{}},
+		{"{ lua.check_token }", "{ lua.check_token }", []string{}},
+		{"{ table_http_req_rate(st) gt 10 }", "{ table_http_req_rate(st) gt 10 }", []string{}},
+		{"{ base32+src -m found }", "{ base32+src -m found }", []string{}},
+		{"{ fetch_of_a_future_version }", "{ fetch_of_a_future_version }", []string{}},
+		{"", "", nil},
+		{"is_api ||", "", nil},
+		{"|| is_api", "", nil},
+		{"is_api && is_static", "", nil},
+		{"!!is_api", "", nil},
+		{"! !is_api", "", nil},
+		{"is_api !", "", nil},
+		{"if is_api", "", nil},
+		{"{ src 10.0.0.0/8", "", nil},
+		{"src }", "", nil},
+		{"{ src { dst } }", "", nil},
+		{"{src 10.0.0.0/8 }", "", nil},
+		{"{ }", "", nil},
+		{"{ hdr(host }", "", nil},
+		{"{ @fetch }", "", nil},
+		{`{ path "/a }`, "", nil},
+		{"is/api", "", nil},
+		{"{ path_beg -m }", "", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.condTest, func(t *testing.T) {
+			condition, err := ParseCondition(test.condTest)
+			if test.want == "" {
+				if err == nil {
+					t.Fatalf("parsed as %q, want an error", condition)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := condition.String(); got != test.want {
+				t.Fatalf("parsed as %q, want %q", got, test.want)
+			}
+			if got := condition.AclNames(); !reflect.DeepEqual(got, test.acls) {
+				t.Fatalf("acl names %v, want %v", got, test.acls)
+			}
+		})
+	}
+}
+
+func TestValidateCondition(t *testing.T) {
+	tests := []struct {
+		cond     string
+		condTest string
+		valid    bool
+	}{
+		{"", "", true},
+		{CondIf, "is_api", true},
+		{CondUnless, "is_api || { src 10.0.0.0/8 }", true},
+		{"when", "is_api", false},
+		{"", "is_api", false},
+		{CondIf, "", false},
+		{CondIf, "is_api ||", false},
+	}
+	for _, test := range tests {
+		err := ValidateCondition(test.cond, test.condTest)
+		if (err == nil) != test.valid {
+			t.Errorf("ValidateCondition(%q, %q) = %v, want valid %v", test.cond, test.condTest, err, test.valid)
+		}
+	}
+}
+
+func TestConditionBuilders(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		want      string
+	}{
+		{"and", And(AclRef("a"), AclRef("b")), "a b"},
+		{"or", Or(AclRef("a"), AclRef("b")), "a || b"},
+		{"and of ors", And(Or(AclRef("a"), AclRef("b")), AclRef("c")), "a c || b c"},
+		{"not of and", Not(And(AclRef("a"), AclRef("b"))), "!a || !b"},
+		{"not of or", Not(Or(AclRef("a"), AclRef("b"))), "!a !b"},
+		{"double not", Not(Not(AclRef("a"))), "a"},
+		{"anonymous", And(AclRef("a"), Not(Anonymous(Src("10.0.0.0/8")))), "a !{ src 10.0.0.0/8 }"},
+		{"flags", Anonymous(Hdr("host", "a.example.com").IgnoreCase()), "{ hdr(host) -i a.example.com }"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.condition.String(); got != test.want {
+				t.Fatalf("%q, want %q", got, test.want)
+			}
+			// the printed condition parses back to the same one
+			parsed, err := ParseCondition(test.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*parsed, test.condition) {
+				t.Fatalf("parsed back as %+v, want %+v", *parsed, test.condition)
+			}
+		})
+	}
+}
+
+func TestKnownFetch(t *testing.T) {
+	tests := []struct {
+		fetch string
+		known bool
+	}{
+		{"path_beg", true},
+		{"req.hdr(host),lower", true},
+		{"be_name", true},
+		{"sc0_http_req_rate(st)", true},
+		{"table_conn_cnt(st)", true},
+		{"lua.check_token", true},
+		{"fetch_of_a_future_version", false},
+	}
+	for _, test := range tests {
+		if got := KnownFetch(test.fetch); got != test.known {
+			t.Errorf("KnownFetch(%q) = %v, want %v", test.fetch, got, test.known)
+		}
+	}
+}
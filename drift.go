@@ -0,0 +1,203 @@
+package haproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// the differences between the configurations of nodes which should be identical
+type HaproxyDriftReport struct {
+	Nodes     []string               `json:"nodes"`
+	Resources []HaproxyResourceDrift `json:"resources"` // only the resources which differ
+	Summary   []HaproxyNodeDrift     `json:"summary"`   // the nodes diverging from the majority, most divergent first
+	// the nodes whose configuration could not be read, by name, they are left out of the comparison
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// a resource is identified by its path, eg: "backend/app", "backend/app/server/app1" or "frontend/www/acls"
+// (ordered lists are compared as a whole)
+type HaproxyResourceDrift struct {
+	Resource string              `json:"resource"`
+	Groups   []HaproxyDriftGroup `json:"groups"` // the nodes sharing the same definition, biggest group first
+	Majority []string            `json:"majority"`
+	// the nodes outside the majority, all the nodes when there is no strict majority
+	Divergent []string `json:"divergent"`
+}
+
+type HaproxyDriftGroup struct {
+	Nodes   []string `json:"nodes"`
+	Present bool     `json:"present"` // false for the nodes missing the resource
+	// the field level differences from the majority definition: Current holds the majority value, Desired the group one
+	Fields []HaproxyFieldDiff `json:"fields,omitempty"`
+}
+
+type HaproxyNodeDrift struct {
+	Node      string   `json:"node"`
+	Resources []string `json:"resources"`
+}
+
+func (r *HaproxyDriftReport) HasDrift() bool {
+	return len(r.Resources) > 0
+}
+
+// export the configuration of every node (concurrently) and compare them, nodes are keyed by a name used in the report.
+//
+// A node failing the export is reported in Errors and the other ones are still compared, an error
+// is returned only when no node could be exported.
+func DetectDrift(nodes map[string]IHaproxyClient) (*HaproxyDriftReport, error) {
+	states := map[string]*HaproxyDesiredState{}
+	errs := map[string]error{}
+	var lock sync.Mutex
+	var wait sync.WaitGroup
+	for name, client := range nodes {
+		wait.Add(1)
+		go func(name string, client IHaproxyClient) {
+			defer wait.Done()
+			state, err := client.Export()
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs[name] = err
+				return
+			}
+			states[name] = state
+		}(name, client)
+	}
+	wait.Wait()
+	if len(states) == 0 && len(errs) > 0 {
+		name := sortedKeys(errs)[0]
+		return nil, fmt.Errorf("no node could be exported, node %s: %w", name, errs[name])
+	}
+	report := CompareStates(states)
+	for name, err := range errs {
+		if report.Errors == nil {
+			report.Errors = map[string]string{}
+		}
+		report.Errors[name] = err.Error()
+	}
+	return report, nil
+}
+
+// compare the configurations of several nodes, see DetectDrift
+func CompareStates(states map[string]*HaproxyDesiredState) *HaproxyDriftReport {
+	nodes := sortedKeys(states)
+	resources := map[string]map[string]interface{}{}
+	for _, node := range nodes {
+		for path, value := range stateResources(states[node]) {
+			if resources[path] == nil {
+				resources[path] = map[string]interface{}{}
+			}
+			resources[path][node] = value
+		}
+	}
+
+	report := HaproxyDriftReport{Nodes: nodes, Resources: []HaproxyResourceDrift{}, Summary: []HaproxyNodeDrift{}}
+	divergent := map[string][]string{}
+	for _, path := range sortedKeys(resources) {
+		values := resources[path]
+		// group the nodes by their canonical definition, "" standing for a missing resource
+		groups := map[string][]string{}
+		keys := []string{}
+		for _, node := range nodes {
+			key := ""
+			if value, exist := values[node]; exist {
+				data, _ := json.Marshal(value)
+				key = string(data)
+			}
+			if _, exist := groups[key]; !exist {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], node)
+		}
+		if len(keys) == 1 {
+			continue
+		}
+		sort.SliceStable(keys, func(i, j int) bool { return len(groups[keys[i]]) > len(groups[keys[j]]) })
+
+		drift := HaproxyResourceDrift{Resource: path, Groups: []HaproxyDriftGroup{}, Majority: []string{}, Divergent: []string{}}
+		hasMajority := len(groups[keys[0]]) > len(groups[keys[1]])
+		if hasMajority {
+			drift.Majority = groups[keys[0]]
+		}
+		for i, key := range keys {
+			group := HaproxyDriftGroup{Nodes: groups[key], Present: key != ""}
+			if !hasMajority || i > 0 {
+				drift.Divergent = append(drift.Divergent, groups[key]...)
+			}
+			if hasMajority && i > 0 {
+				group.Fields = fieldDiffs(values[drift.Majority[0]], values[groups[key][0]])
+			}
+			drift.Groups = append(drift.Groups, group)
+		}
+		sort.Strings(drift.Divergent)
+		for _, node := range drift.Divergent {
+			divergent[node] = append(divergent[node], path)
+		}
+		report.Resources = append(report.Resources, drift)
+	}
+
+	for _, node := range nodes {
+		if len(divergent[node]) > 0 {
+			report.Summary = append(report.Summary, HaproxyNodeDrift{Node: node, Resources: divergent[node]})
+		}
+	}
+	sort.SliceStable(report.Summary, func(i, j int) bool {
+		return len(report.Summary[i].Resources) > len(report.Summary[j].Resources)
+	})
+	return &report
+}
+
+// the resources of a state keyed by path, the children lists are indexed by position
+func stateResources(state *HaproxyDesiredState) map[string]interface{} {
+	resources := map[string]interface{}{}
+	list := func(path string, value interface{}, length int) {
+		if length > 0 {
+			resources[path] = value
+		}
+	}
+	for _, f := range state.Frontends {
+		path := "frontend/" + f.Frontend.Name
+		resources[path] = f.Frontend
+		for _, bind := range f.Binds {
+			resources[path+"/bind/"+bind.Name] = bind
+		}
+		list(path+"/acls", indexedList(f.Acls), len(f.Acls))
+		list(path+"/http_request_rules", indexedList(f.HttpRequestRules), len(f.HttpRequestRules))
+		list(path+"/http_response_rules", indexedList(f.HttpResponseRules), len(f.HttpResponseRules))
+		list(path+"/tcp_request_rules", indexedList(f.TcpRequestRules), len(f.TcpRequestRules))
+		list(path+"/backend_switching_rules", indexedList(f.BackendSwitchingRules), len(f.BackendSwitchingRules))
+	}
+	for _, b := range state.Backends {
+		path := "backend/" + b.Backend.Name
+		resources[path] = b.Backend
+		for _, server := range b.Servers {
+			resources[path+"/server/"+server.Name] = server
+		}
+		list(path+"/acls", indexedList(b.Acls), len(b.Acls))
+		list(path+"/http_request_rules", indexedList(b.HttpRequestRules), len(b.HttpRequestRules))
+		list(path+"/http_response_rules", indexedList(b.HttpResponseRules), len(b.HttpResponseRules))
+		list(path+"/tcp_request_rules", indexedList(b.TcpRequestRules), len(b.TcpRequestRules))
+		list(path+"/tcp_response_rules", indexedList(b.TcpResponseRules), len(b.TcpResponseRules))
+		list(path+"/server_switching_rules", indexedList(b.ServerSwitchingRules), len(b.ServerSwitchingRules))
+	}
+	return resources
+}
+
+func indexedList[T any](rules []T) []T {
+	indexed := make([]T, len(rules))
+	for i, rule := range rules {
+		indexed[i] = *ruleAt(rule, i)
+	}
+	return indexed
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,108 @@
+package haproxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+const driftState = `
+backends:
+  - backend: {name: app, mode: http, balance: {algorithm: roundrobin}}
+    servers:
+      - {name: app1, address: 10.0.0.1, port: 8080}
+frontends:
+  - frontend: {name: www, mode: http, default_backend: app}
+    acls:
+      - {acl_name: is_api, criterion: path_beg, value: /api}
+`
+
+func TestCompareStates(t *testing.T) {
+	tests := []struct {
+		name      string
+		states    map[string]string
+		resources []string
+		majority  map[string][]string // by resource
+		divergent map[string][]string // by resource
+		summary   []HaproxyNodeDrift
+	}{
+		{
+			"identical",
+			map[string]string{"lb1": driftState, "lb2": driftState, "lb3": driftState},
+			[]string{}, nil, nil, []HaproxyNodeDrift{},
+		},
+		{
+			"one node differs",
+			map[string]string{"lb1": driftState, "lb2": driftState, "lb3": `
+backends:
+  - backend: {name: app, mode: http, balance: {algorithm: leastconn}}
+    servers:
+      - {name: app1, address: 10.0.0.1, port: 8080}
+      - {name: app2, address: 10.0.0.2, port: 8080}
+frontends:
+  - frontend: {name: www, mode: http, default_backend: app}
+    acls:
+      - {acl_name: is_api, criterion: path_beg, value: /api}
+`},
+			[]string{"backend/app", "backend/app/server/app2"},
+			map[string][]string{"backend/app": {"lb1", "lb2"}, "backend/app/server/app2": {"lb1", "lb2"}},
+			map[string][]string{"backend/app": {"lb3"}, "backend/app/server/app2": {"lb3"}},
+			[]HaproxyNodeDrift{{Node: "lb3", Resources: []string{"backend/app", "backend/app/server/app2"}}},
+		},
+		{
+			"no majority",
+			map[string]string{"lb1": driftState, "lb2": `
+backends:
+  - backend: {name: app, mode: http, balance: {algorithm: roundrobin}}
+    servers:
+      - {name: app1, address: 10.0.0.1, port: 8080}
+frontends:
+  - frontend: {name: www, mode: http, default_backend: app}
+`},
+			[]string{"frontend/www/acls"},
+			map[string][]string{"frontend/www/acls": {}},
+			map[string][]string{"frontend/www/acls": {"lb1", "lb2"}},
+			[]HaproxyNodeDrift{{Node: "lb1", Resources: []string{"frontend/www/acls"}}, {Node: "lb2", Resources: []string{"frontend/www/acls"}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			states := map[string]*HaproxyDesiredState{}
+			for node, document := range test.states {
+				states[node] = parseState(t, document)
+			}
+			report := CompareStates(states)
+			resources := []string{}
+			for _, drift := range report.Resources {
+				resources = append(resources, drift.Resource)
+				if !reflect.DeepEqual(drift.Majority, test.majority[drift.Resource]) {
+					t.Errorf("%s majority %v, want %v", drift.Resource, drift.Majority, test.majority[drift.Resource])
+				}
+				if !reflect.DeepEqual(drift.Divergent, test.divergent[drift.Resource]) {
+					t.Errorf("%s divergent %v, want %v", drift.Resource, drift.Divergent, test.divergent[drift.Resource])
+				}
+			}
+			if !reflect.DeepEqual(resources, test.resources) {
+				t.Fatalf("resources %v, want %v", resources, test.resources)
+			}
+			if !reflect.DeepEqual(report.Summary, test.summary) {
+				t.Fatalf("summary %+v, want %+v", report.Summary, test.summary)
+			}
+			if report.HasDrift() != (len(test.resources) > 0) {
+				t.Fatalf("HasDrift %v with %d resources", report.HasDrift(), len(test.resources))
+			}
+		})
+	}
+}
+
+func TestCompareStatesFields(t *testing.T) {
+	changed := parseState(t, driftState)
+	changed.Backends[0].Backend.Balance.Algorithm = BalanceLeastConn
+	report := CompareStates(map[string]*HaproxyDesiredState{"lb1": parseState(t, driftState), "lb2": parseState(t, driftState), "lb3": changed})
+	if len(report.Resources) != 1 || len(report.Resources[0].Groups) != 2 {
+		t.Fatalf("unexpected report %+v", report.Resources)
+	}
+	want := []HaproxyFieldDiff{{Path: "balance.algorithm", Current: "roundrobin", Desired: "leastconn"}}
+	if got := report.Resources[0].Groups[1].Fields; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fields %+v, want %+v", got, want)
+	}
+}
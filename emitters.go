@@ -0,0 +1,295 @@
+package haproxy
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// pushes sampled rates to an external metrics system
+type HaproxyStatsEmitter interface {
+	Emit(rates []HaproxyStatRates) error
+}
+
+// a field which can be selected in the emitters, value is the increase over the sampled window for
+// the counters, which also have delta to read the increase from a HaproxyStatDelta
+type statField struct {
+	value func(r *HaproxyStatRates) float64
+	delta func(d *HaproxyStatDelta) int
+}
+
+func gaugeField(value func(r *HaproxyStatRates) float64) statField {
+	return statField{value: value}
+}
+
+func counterField(delta func(d *HaproxyStatDelta) int) statField {
+	return statField{value: func(r *HaproxyStatRates) float64 { return float64(delta(&r.Delta)) }, delta: delta}
+}
+
+var statFields = map[string]statField{
+	"request_rate":      gaugeField(func(r *HaproxyStatRates) float64 { return r.RequestRate }),
+	"error_ratio":       gaugeField(func(r *HaproxyStatRates) float64 { return r.ErrorRatio }),
+	"bytes_in_rate":     gaugeField(func(r *HaproxyStatRates) float64 { return r.BytesInRate }),
+	"bytes_out_rate":    gaugeField(func(r *HaproxyStatRates) float64 { return r.BytesOutRate }),
+	"current_sessions":  gaugeField(func(r *HaproxyStatRates) float64 { return float64(r.Current.Scur) }),
+	"current_queue":     gaugeField(func(r *HaproxyStatRates) float64 { return float64(r.Current.Qcur) }),
+	"weight":            gaugeField(func(r *HaproxyStatRates) float64 { return float64(r.Current.Weight) }),
+	"up":                gaugeField(func(r *HaproxyStatRates) float64 { return boolFloat(IsServerUp(r.Current.Status)) }),
+	"requests":          counterField(func(d *HaproxyStatDelta) int { return d.Requests }),
+	"responses":         counterField(func(d *HaproxyStatDelta) int { return d.Responses }),
+	"responses_4xx":     counterField(func(d *HaproxyStatDelta) int { return d.Responses4xx }),
+	"responses_5xx":     counterField(func(d *HaproxyStatDelta) int { return d.Responses5xx }),
+	"sessions":          counterField(func(d *HaproxyStatDelta) int { return d.Sessions }),
+	"bytes_in":          counterField(func(d *HaproxyStatDelta) int { return d.BytesIn }),
+	"bytes_out":         counterField(func(d *HaproxyStatDelta) int { return d.BytesOut }),
+	"request_errors":    counterField(func(d *HaproxyStatDelta) int { return d.RequestErrors }),
+	"connection_errors": counterField(func(d *HaproxyStatDelta) int { return d.ConnectionErrors }),
+	"response_errors":   counterField(func(d *HaproxyStatDelta) int { return d.ResponseErrors }),
+}
+
+// the fields emitted when none are selected
+var DefaultStatFields = []string{"request_rate", "error_ratio", "bytes_in_rate", "bytes_out_rate", "current_sessions", "requests", "responses_5xx"}
+
+// the available field names, sorted
+func StatFieldNames() []string {
+	return sortedKeys(statFields)
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// the selected fields, an unknown field name is an error
+func selectFields(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return DefaultStatFields, nil
+	}
+	for _, name := range names {
+		if _, exist := statFields[name]; !exist {
+			return nil, fmt.Errorf("unknown stat field %q, available fields: %s", name, strings.Join(StatFieldNames(), ", "))
+		}
+	}
+	return names, nil
+}
+
+// the tags of a proxy or server: "type", "proxy" and "server" renamed through tagNames
+// (an empty name drops the tag) plus the static tags
+func statTags(key HaproxyStatKey, tagNames map[string]string, static map[string]string) map[string]string {
+	tags := map[string]string{}
+	for name, value := range static {
+		tags[name] = value
+	}
+	for name, value := range map[string]string{"type": key.Type, "proxy": key.Proxy, "server": key.Server} {
+		if renamed, exist := tagNames[name]; exist {
+			name = renamed
+		}
+		if name != "" && value != "" {
+			tags[name] = value
+		}
+	}
+	return tags
+}
+
+// emits to statsd over udp, eg: haproxy.backend.app.request_rate:12.5|g
+//
+// Rates and gauges are sent as gauges, the counters as the increase since the previous sample whatever
+// the window, so that the overlapping windows of successive emits are not counted twice. When Tagged is set
+// the tags are sent in the dogstatsd format (|#proxy:app) instead of being part of the metric name.
+type HaproxyStatsdEmitter struct {
+	Address  string            // host:port
+	Prefix   string            // prepended to the metric names, eg: "haproxy"
+	Fields   []string          // the fields to send, DefaultStatFields when empty
+	Tagged   bool              // dogstatsd tags instead of dotted names
+	TagNames map[string]string // rename or drop ("") the type, proxy and server tags
+	Tags     map[string]string // static tags, only sent when Tagged
+	// the maximum size of a datagram, 1432 bytes when zero
+	MaxPacketSize int
+}
+
+func NewHaproxyStatsdEmitter(address string, prefix string) *HaproxyStatsdEmitter {
+	return &HaproxyStatsdEmitter{Address: address, Prefix: prefix}
+}
+
+func (e *HaproxyStatsdEmitter) Emit(rates []HaproxyStatRates) error {
+	fields, err := selectFields(e.Fields)
+	if err != nil {
+		return err
+	}
+	conn, err := net.Dial("udp", e.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	maxSize := e.MaxPacketSize
+	if maxSize <= 0 {
+		maxSize = 1432
+	}
+	packet := bytes.Buffer{}
+	flush := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := conn.Write(packet.Bytes())
+		packet.Reset()
+		return err
+	}
+	for i := range rates {
+		for _, line := range e.lines(&rates[i], fields) {
+			if packet.Len() > 0 && packet.Len()+1+len(line) > maxSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if packet.Len() > 0 {
+				packet.WriteByte('\n')
+			}
+			packet.WriteString(line)
+		}
+	}
+	return flush()
+}
+
+func (e *HaproxyStatsdEmitter) lines(rates *HaproxyStatRates, fields []string) []string {
+	path := []string{}
+	if e.Prefix != "" {
+		path = append(path, e.Prefix)
+	}
+	suffix := ""
+	if e.Tagged {
+		tags := statTags(rates.Key, e.TagNames, e.Tags)
+		pairs := []string{}
+		for _, name := range sortedKeys(tags) {
+			pairs = append(pairs, statsdName(name)+":"+statsdName(tags[name]))
+		}
+		if len(pairs) > 0 {
+			suffix = "|#" + strings.Join(pairs, ",")
+		}
+	} else {
+		tags := statTags(rates.Key, e.TagNames, nil)
+		for _, name := range []string{"type", "proxy", "server"} {
+			if renamed, exist := e.TagNames[name]; exist {
+				name = renamed
+			}
+			if value, exist := tags[name]; exist {
+				path = append(path, statsdName(value))
+			}
+		}
+	}
+	lines := []string{}
+	for _, name := range fields {
+		field := statFields[name]
+		value, kind := field.value(rates), "g"
+		if field.delta != nil {
+			value, kind = float64(field.delta(&rates.LastDelta)), "c"
+		}
+		metric := strings.Join(append(path, name), ".")
+		lines = append(lines, metric+":"+strconv.FormatFloat(value, 'f', -1, 64)+"|"+kind+suffix)
+	}
+	return lines
+}
+
+// statsd names can't contain the separators of the protocol
+func statsdName(name string) string {
+	return strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_").Replace(name)
+}
+
+// emits to influxdb in line protocol over http, eg: haproxy_backend,proxy=app request_rate=12.5,requests=125i 1666000000000000000
+//
+// Url is the full write endpoint, eg: http://influxdb:8086/api/v2/write?org=acme&bucket=haproxy&precision=ns
+// (or /write?db=haproxy for influxdb 1.x), the timestamps are in nanoseconds.
+type HaproxyInfluxEmitter struct {
+	Url      string
+	Token    string            // sent as "Authorization: Token <token>" when set
+	Prefix   string            // the measurement is <prefix>_<type>, or just the type when empty
+	Fields   []string          // the fields to send, DefaultStatFields when empty
+	TagNames map[string]string // rename or drop ("") the type, proxy and server tags
+	Tags     map[string]string // static tags
+	Rest     *resty.Client
+}
+
+func NewHaproxyInfluxEmitter(url string, token string, prefix string) *HaproxyInfluxEmitter {
+	return &HaproxyInfluxEmitter{Url: url, Token: token, Prefix: prefix, Rest: resty.New().SetTimeout(10 * time.Second)}
+}
+
+func (e *HaproxyInfluxEmitter) Emit(rates []HaproxyStatRates) error {
+	fields, err := selectFields(e.Fields)
+	if err != nil {
+		return err
+	}
+	if len(rates) == 0 {
+		return nil
+	}
+	body := strings.Builder{}
+	for i := range rates {
+		body.WriteString(e.line(&rates[i], fields))
+		body.WriteByte('\n')
+	}
+	request := e.Rest.R().
+		SetHeader("Content-Type", "text/plain; charset=utf-8").
+		SetBody(body.String())
+	if e.Token != "" {
+		request.SetHeader("Authorization", "Token "+e.Token)
+	}
+	resp, err := request.Post(e.Url)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("influxdb write failed: %s %s", resp.Status(), strings.TrimSpace(resp.String()))
+	}
+	return nil
+}
+
+func (e *HaproxyInfluxEmitter) line(rates *HaproxyStatRates, fields []string) string {
+	measurement := rates.Key.Type
+	if e.Prefix != "" {
+		measurement = e.Prefix + "_" + measurement
+	}
+	line := strings.Builder{}
+	line.WriteString(influxEscape(measurement, false))
+	tags := statTags(rates.Key, e.TagNames, e.Tags)
+	for _, name := range sortedKeys(tags) {
+		line.WriteString("," + influxEscape(name, true) + "=" + influxEscape(tags[name], true))
+	}
+	for i, name := range fields {
+		field := statFields[name]
+		separator := ","
+		if i == 0 {
+			separator = " "
+		}
+		value := strconv.FormatFloat(field.value(rates), 'f', -1, 64)
+		if field.delta != nil {
+			value += "i"
+		}
+		line.WriteString(separator + influxEscape(name, true) + "=" + value)
+	}
+	line.WriteString(" " + strconv.FormatInt(rates.To.UnixNano(), 10))
+	return line.String()
+}
+
+// escape a measurement, tag or field key/value of the line protocol
+func influxEscape(s string, tag bool) string {
+	replacer := strings.NewReplacer(",", `\,`, " ", `\ `)
+	if tag {
+		replacer = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+	}
+	return replacer.Replace(s)
+}
+
+// emit the rates over window of every proxy and server after each sample, the emitter errors
+// are passed to onError when it is not nil
+func (s *HaproxyStatsSampler) EmitTo(emitter HaproxyStatsEmitter, window time.Duration, onError func(err error)) {
+	s.OnSample(func(sampler *HaproxyStatsSampler) {
+		if err := emitter.Emit(sampler.AllRates(window)); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
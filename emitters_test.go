@@ -0,0 +1,104 @@
+package haproxy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatsdLines(t *testing.T) {
+	rates := HaproxyStatRates{
+		Key:         HaproxyStatKey{Type: "server", Proxy: "app.v2", Server: "app1"},
+		RequestRate: 12.5,
+		Delta:       HaproxyStatDelta{Requests: 125},
+		LastDelta:   HaproxyStatDelta{Requests: 25},
+	}
+	tests := []struct {
+		name    string
+		emitter HaproxyStatsdEmitter
+		want    []string
+	}{
+		{
+			"dotted names",
+			HaproxyStatsdEmitter{Prefix: "haproxy", Fields: []string{"request_rate", "requests"}},
+			[]string{"haproxy.server.app_v2.app1.request_rate:12.5|g", "haproxy.server.app_v2.app1.requests:25|c"},
+		},
+		{
+			"dropped type without prefix",
+			HaproxyStatsdEmitter{Fields: []string{"request_rate"}, TagNames: map[string]string{"type": ""}},
+			[]string{"app_v2.app1.request_rate:12.5|g"},
+		},
+		{
+			"dogstatsd tags",
+			HaproxyStatsdEmitter{
+				Prefix:   "haproxy",
+				Fields:   []string{"requests"},
+				Tagged:   true,
+				TagNames: map[string]string{"proxy": "backend"},
+				Tags:     map[string]string{"env": "prod"},
+			},
+			[]string{"haproxy.requests:25|c|#backend:app_v2,env:prod,server:app1,type:server"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields, err := selectFields(test.emitter.Fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lines := test.emitter.lines(&rates, fields); !reflect.DeepEqual(lines, test.want) {
+				t.Fatalf("lines %q, want %q", lines, test.want)
+			}
+		})
+	}
+}
+
+func TestInfluxLine(t *testing.T) {
+	rates := HaproxyStatRates{
+		Key:         HaproxyStatKey{Type: "backend", Proxy: "my app"},
+		To:          time.Unix(1666000000, 0),
+		RequestRate: 12.5,
+		ErrorRatio:  0.1,
+		Delta:       HaproxyStatDelta{Requests: 125},
+	}
+	tests := []struct {
+		name    string
+		emitter HaproxyInfluxEmitter
+		want    string
+	}{
+		{
+			"prefixed measurement",
+			HaproxyInfluxEmitter{Prefix: "haproxy", Fields: []string{"request_rate", "requests"}},
+			`haproxy_backend,proxy=my\ app,type=backend request_rate=12.5,requests=125i 1666000000000000000`,
+		},
+		{
+			"renamed and static tags",
+			HaproxyInfluxEmitter{
+				Fields:   []string{"error_ratio"},
+				TagNames: map[string]string{"type": "", "proxy": "backend"},
+				Tags:     map[string]string{"dc": "eu=west,1"},
+			},
+			`backend,backend=my\ app,dc=eu\=west\,1 error_ratio=0.1 1666000000000000000`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields, err := selectFields(test.emitter.Fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line := test.emitter.line(&rates, fields); line != test.want {
+				t.Fatalf("line %q, want %q", line, test.want)
+			}
+		})
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	if fields, err := selectFields(nil); err != nil || !reflect.DeepEqual(fields, DefaultStatFields) {
+		t.Fatalf("fields %v (%v), want the defaults", fields, err)
+	}
+	if _, err := selectFields([]string{"requests", "latency"}); err == nil {
+		t.Fatal("no error for an unknown field")
+	}
+}
@@ -0,0 +1,181 @@
+package haproxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// identifies a backend, or a server within its Backend
+type HaproxyResourceRef struct {
+	Kind    string `json:"kind"` // ResourceBackend or ResourceServer
+	Name    string `json:"name"`
+	Backend string `json:"backend,omitempty"`
+}
+
+// a configuration object pointing to the resource
+type HaproxyReference struct {
+	Kind       string `json:"kind"` // ResourceFrontend, ResourceBackendSwitchingRule or ResourceServerSwitchingRule
+	ParentType string `json:"parent_type,omitempty"`
+	ParentName string `json:"parent_name"`
+	Index      *int   `json:"index,omitempty"`
+	Message    string `json:"message"`
+}
+
+// what removing a resource would break: the objects referencing it and its live traffic per native stats
+type HaproxyImpact struct {
+	Resource        HaproxyResourceRef `json:"resource"`
+	References      []HaproxyReference `json:"references"`
+	Status          string             `json:"status"`
+	CurrentSessions int                `json:"current_sessions"`
+	SessionRate     int                `json:"session_rate"`
+	RequestRate     int                `json:"request_rate"`
+	UpServers       int                `json:"up_servers"`               // the UP servers of the backend
+	LastUpServer    bool               `json:"last_up_server,omitempty"` // the server is the only one UP in its backend
+	Reasons         []string           `json:"reasons"`                  // why the removal is risky
+}
+
+// deleting the resource is risky when something references it, it serves traffic or it is the last UP server
+func (i *HaproxyImpact) Risky() bool {
+	return len(i.Reasons) > 0
+}
+
+// list what references a backend or a server and how much traffic it currently serves,
+// so that deletion tooling can refuse risky changes
+func (h *haproxyClient) ImpactOf(resource HaproxyResourceRef) (*HaproxyImpact, error) {
+	state, err := h.fetchState()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := h.GetStats()
+	if err != nil {
+		return nil, err
+	}
+	return impactOf(state, *stats, resource)
+}
+
+func impactOf(state *HaproxyDesiredState, stats HaproxyStats, resource HaproxyResourceRef) (*HaproxyImpact, error) {
+	impact := HaproxyImpact{Resource: resource, References: []HaproxyReference{}, Reasons: []string{}}
+	backendName := resource.Name
+	if resource.Kind == ResourceServer {
+		backendName = resource.Backend
+	} else if resource.Kind != ResourceBackend {
+		return nil, fmt.Errorf("impact analysis is not supported for %s", resource.Kind)
+	}
+	var backend *HaproxyDesiredBackend
+	for i := range state.Backends {
+		if state.Backends[i].Backend.Name == backendName {
+			backend = &state.Backends[i]
+		}
+	}
+	if backend == nil {
+		return nil, fmt.Errorf("backend %s not found", backendName)
+	}
+
+	switch resource.Kind {
+	case ResourceBackend:
+		for _, f := range state.Frontends {
+			if f.Frontend.DefaultBackend == resource.Name {
+				impact.References = append(impact.References, HaproxyReference{
+					Kind:       ResourceFrontend,
+					ParentName: f.Frontend.Name,
+					Message:    fmt.Sprintf("frontend %s uses it as default_backend", f.Frontend.Name),
+				})
+			}
+			for _, rule := range f.BackendSwitchingRules {
+				if rule.Name == resource.Name {
+					index := rule.Index
+					impact.References = append(impact.References, HaproxyReference{
+						Kind:       ResourceBackendSwitchingRule,
+						ParentType: ResourceFrontend,
+						ParentName: f.Frontend.Name,
+						Index:      &index,
+						Message:    fmt.Sprintf("frontend %s routes to it %s %s", f.Frontend.Name, rule.Cond, rule.CondTest),
+					})
+				}
+			}
+		}
+		values := stats.sum(func(stat HaproxyNativeStat) bool { return stat.Type == "backend" && stat.Name == resource.Name })
+		impact.Status = values.Status
+		impact.CurrentSessions, impact.SessionRate, impact.RequestRate = values.Scur, values.Rate, values.ReqRate
+		impact.UpServers = len(stats.upServers(resource.Name))
+
+	case ResourceServer:
+		found := false
+		for _, server := range backend.Servers {
+			found = found || server.Name == resource.Name
+		}
+		if !found {
+			return nil, fmt.Errorf("server %s not found in backend %s", resource.Name, backendName)
+		}
+		for _, rule := range backend.ServerSwitchingRules {
+			if rule.TargetServer == resource.Name {
+				index := rule.Index
+				impact.References = append(impact.References, HaproxyReference{
+					Kind:       ResourceServerSwitchingRule,
+					ParentType: ResourceBackend,
+					ParentName: backendName,
+					Index:      &index,
+					Message:    fmt.Sprintf("backend %s uses it %s %s", backendName, rule.Cond, rule.CondTest),
+				})
+			}
+		}
+		values := stats.sum(func(stat HaproxyNativeStat) bool {
+			return stat.Type == "server" && stat.BackendName == backendName && stat.Name == resource.Name
+		})
+		impact.Status = values.Status
+		impact.CurrentSessions, impact.SessionRate, impact.RequestRate = values.Scur, values.Rate, values.ReqRate
+		up := stats.upServers(backendName)
+		impact.UpServers = len(up)
+		impact.LastUpServer = len(up) == 1 && up[0] == resource.Name
+	}
+
+	for _, reference := range impact.References {
+		impact.Reasons = append(impact.Reasons, reference.Message)
+	}
+	if impact.CurrentSessions > 0 || impact.SessionRate > 0 {
+		impact.Reasons = append(impact.Reasons, fmt.Sprintf("%d current sessions, %d sessions/s", impact.CurrentSessions, impact.SessionRate))
+	}
+	if impact.LastUpServer {
+		impact.Reasons = append(impact.Reasons, fmt.Sprintf("last UP server of backend %s", backendName))
+	}
+	return &impact, nil
+}
+
+// the values of the matching stats summed over the runtime apis, Status is the first one found
+func (s HaproxyStats) sum(match func(stat HaproxyNativeStat) bool) HaproxyNativeStatValues {
+	total := HaproxyNativeStatValues{}
+	for _, collection := range s {
+		for _, stat := range collection.Stats {
+			if !match(stat) {
+				continue
+			}
+			if total.Status == "" {
+				total.Status = stat.Stats.Status
+			}
+			total.Scur += stat.Stats.Scur
+			total.Rate += stat.Stats.Rate
+			total.ReqRate += stat.Stats.ReqRate
+		}
+	}
+	return total
+}
+
+// the servers of a backend in UP state on any runtime api
+func (s HaproxyStats) upServers(backend string) []string {
+	up := []string{}
+	seen := map[string]bool{}
+	for _, collection := range s {
+		for _, stat := range collection.Stats {
+			if stat.Type == "server" && stat.BackendName == backend && IsServerUp(stat.Stats.Status) && !seen[stat.Name] {
+				seen[stat.Name] = true
+				up = append(up, stat.Name)
+			}
+		}
+	}
+	return up
+}
+
+// a server status is UP when checks pass ("UP", or "UP 1/3" while going down) or when it is not checked
+func IsServerUp(status string) bool {
+	return strings.HasPrefix(status, "UP") || status == "no check"
+}
@@ -0,0 +1,128 @@
+package haproxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+const impactState = `
+backends:
+  - backend: {name: app}
+    servers:
+      - {name: app1, address: 10.0.0.1, port: 8080}
+      - {name: app2, address: 10.0.0.2, port: 8080}
+    server_switching_rules: [{target_server: app2, cond: if, cond_test: is_sticky}]
+  - backend: {name: api}
+    servers: [{name: api1, address: 10.0.0.3, port: 8080}]
+frontends:
+  - frontend: {name: www, default_backend: app}
+    backend_switching_rules: [{name: api, cond: if, cond_test: is_api}]
+`
+
+func TestImpactOf(t *testing.T) {
+	server := func(backend string, name string, status string, scur int) HaproxyNativeStat {
+		return HaproxyNativeStat{Type: "server", BackendName: backend, Name: name, Stats: HaproxyNativeStatValues{Status: status, Scur: scur}}
+	}
+	stats := HaproxyStats{
+		{Stats: []HaproxyNativeStat{
+			{Type: "backend", Name: "app", Stats: HaproxyNativeStatValues{Status: "UP", Scur: 3, Rate: 2, ReqRate: 5}},
+			{Type: "backend", Name: "api", Stats: HaproxyNativeStatValues{Status: "UP"}},
+			server("app", "app1", "UP", 3),
+			server("app", "app2", "DOWN", 0),
+			server("api", "api1", "UP 1/3", 0),
+		}},
+		// a second process adds its sessions
+		{Stats: []HaproxyNativeStat{
+			{Type: "backend", Name: "app", Stats: HaproxyNativeStatValues{Status: "UP", Scur: 1}},
+		}},
+	}
+	tests := []struct {
+		name       string
+		resource   HaproxyResourceRef
+		references []string
+		sessions   int
+		up         int
+		last       bool
+		risky      bool
+	}{
+		{
+			"backend used as default and with traffic",
+			HaproxyResourceRef{Kind: ResourceBackend, Name: "app"},
+			[]string{"frontend www uses it as default_backend"},
+			4, 1, false, true,
+		},
+		{
+			"backend routed to by a rule",
+			HaproxyResourceRef{Kind: ResourceBackend, Name: "api"},
+			[]string{"frontend www routes to it if is_api"},
+			0, 1, false, true,
+		},
+		{
+			"last up server going down",
+			HaproxyResourceRef{Kind: ResourceServer, Name: "api1", Backend: "api"},
+			[]string{},
+			0, 1, true, true,
+		},
+		{
+			"down server used by a switching rule",
+			HaproxyResourceRef{Kind: ResourceServer, Name: "app2", Backend: "app"},
+			[]string{"backend app uses it if is_sticky"},
+			0, 1, false, true,
+		},
+	}
+	state := parseState(t, impactState)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			impact, err := impactOf(state, stats, test.resource)
+			if err != nil {
+				t.Fatal(err)
+			}
+			references := []string{}
+			for _, reference := range impact.References {
+				references = append(references, reference.Message)
+			}
+			if !reflect.DeepEqual(references, test.references) {
+				t.Fatalf("references %q, want %q", references, test.references)
+			}
+			if impact.CurrentSessions != test.sessions || impact.UpServers != test.up || impact.LastUpServer != test.last {
+				t.Fatalf("%d sessions, %d up, last %v, want %d, %d and %v", impact.CurrentSessions, impact.UpServers, impact.LastUpServer, test.sessions, test.up, test.last)
+			}
+			if impact.Risky() != test.risky {
+				t.Fatalf("risky %v, want %v: %q", impact.Risky(), test.risky, impact.Reasons)
+			}
+		})
+	}
+}
+
+func TestImpactOfUnknown(t *testing.T) {
+	state := parseState(t, impactState)
+	for _, resource := range []HaproxyResourceRef{
+		{Kind: ResourceBackend, Name: "missing"},
+		{Kind: ResourceServer, Name: "app9", Backend: "app"},
+		{Kind: ResourceFrontend, Name: "www"},
+	} {
+		if _, err := impactOf(state, HaproxyStats{}, resource); err == nil {
+			t.Fatalf("no error for %+v", resource)
+		}
+	}
+}
+
+func TestIsServerUp(t *testing.T) {
+	tests := []struct {
+		status string
+		up     bool
+	}{
+		{"UP", true},
+		{"UP 1/3", true},
+		{"no check", true},
+		{"DOWN", false},
+		{"DOWN 1/2", false},
+		{"MAINT", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if IsServerUp(test.status) != test.up {
+			t.Fatalf("IsServerUp(%q) %v, want %v", test.status, !test.up, test.up)
+		}
+	}
+}
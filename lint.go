@@ -0,0 +1,229 @@
+package haproxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+	LintInfo    LintSeverity = "info"
+)
+
+// the lint checks
+const (
+	LintDanglingDefaultBackend = "dangling_default_backend" // default_backend names an unknown backend
+	LintDanglingSwitchingRule  = "dangling_switching_rule"  // use_backend names an unknown backend
+	LintDanglingUseServer      = "dangling_use_server"      // use-server names an unknown server of the backend
+	LintUndefinedAcl           = "undefined_acl"            // a condition uses an acl not defined in its parent
+	LintEmptyBackend           = "empty_backend"            // traffic is routed to a backend without servers
+	LintUnusedBackend          = "unused_backend"           // nothing routes to the backend
+	LintUnusedAcl              = "unused_acl"               // no condition of the parent uses the acl
+	LintInvalidCondition       = "invalid_condition"        // a cond_test which doesn't parse
+	LintInvalidAcl             = "invalid_acl"              // an acl criterion whose fetch doesn't parse
+	LintUnknownFetch           = "unknown_fetch"            // an acl criterion using a fetch missing from KnownFetches
+)
+
+// the acls predefined by haproxy, usable in any condition
+var predefinedAcls = map[string]bool{
+	"FALSE": true, "HTTP": true, "HTTP_1.0": true, "HTTP_1.1": true, "HTTP_2.0": true, "HTTP_3.0": true,
+	"HTTP_CONTENT": true, "HTTP_URL_ABS": true, "HTTP_URL_SLASH": true, "HTTP_URL_STAR": true,
+	"LOCALHOST": true, "METH_CONNECT": true, "METH_DELETE": true, "METH_GET": true, "METH_HEAD": true,
+	"METH_OPTIONS": true, "METH_POST": true, "METH_PUT": true, "METH_TRACE": true, "RDP_COOKIE": true,
+	"REQ_CONTENT": true, "TRUE": true, "WAIT_END": true,
+}
+
+type HaproxyLintFinding struct {
+	Severity   LintSeverity `json:"severity"`
+	Check      string       `json:"check"`
+	ParentType string       `json:"parent_type"`
+	ParentName string       `json:"parent_name"`
+	Name       string       `json:"name"` // the referenced or unused object
+	Message    string       `json:"message"`
+}
+
+type HaproxyLintReport struct {
+	Findings []HaproxyLintFinding `json:"findings"`
+}
+
+func (r *HaproxyLintReport) HasErrors() bool {
+	for _, finding := range r.Findings {
+		if finding.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+// lint the live configuration, see LintState
+func (h *haproxyClient) Lint() (*HaproxyLintReport, error) {
+	state, err := h.fetchState()
+	if err != nil {
+		return nil, err
+	}
+	return LintState(state), nil
+}
+
+// cross-reference the frontends, switching rules, use-server rules, conditions and servers of a
+// configuration, reporting dangling references (errors), unused backends and empty backends (warnings)
+// and unused acls (info). The unused backends are only info when a frontend routes to a computed
+// backend, eg: use_backend %[req.hdr(host),lower].
+//
+// it works on desired states too, eg: to lint a document before reconciling it.
+func LintState(state *HaproxyDesiredState) *HaproxyLintReport {
+	report := HaproxyLintReport{Findings: []HaproxyLintFinding{}}
+	add := func(severity LintSeverity, check string, parentType string, parentName string, name string, format string, args ...interface{}) {
+		report.Findings = append(report.Findings, HaproxyLintFinding{
+			Severity:   severity,
+			Check:      check,
+			ParentType: parentType,
+			ParentName: parentName,
+			Name:       name,
+			Message:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	backends := map[string]*HaproxyDesiredBackend{}
+	for i := range state.Backends {
+		backends[state.Backends[i].Backend.Name] = &state.Backends[i]
+	}
+	routed := map[string]bool{}
+	dynamicRoutes := []string{} // the frontends routing to a computed backend
+	route := func(frontend string, backend string, check string, what string) {
+		// dynamic names (log-format expressions) can't be resolved statically
+		if strings.Contains(backend, "%[") {
+			dynamicRoutes = append(dynamicRoutes, frontend)
+			return
+		}
+		if backend == "" {
+			return
+		}
+		alreadyRouted := routed[backend]
+		routed[backend] = true
+		target, exist := backends[backend]
+		if !exist {
+			add(LintError, check, ResourceFrontend, frontend, backend, "%s routes to the unknown backend %s", what, backend)
+		} else if len(target.Servers) == 0 && !alreadyRouted {
+			add(LintWarning, LintEmptyBackend, ResourceBackend, backend, backend, "backend %s has no server but frontend %s routes to it", backend, frontend)
+		}
+	}
+
+	for _, f := range state.Frontends {
+		name := f.Frontend.Name
+		route(name, f.Frontend.DefaultBackend, LintDanglingDefaultBackend, "default_backend")
+		for i, rule := range f.BackendSwitchingRules {
+			route(name, rule.Name, LintDanglingSwitchingRule, fmt.Sprintf("backend switching rule %d", i))
+		}
+		lintAcls(ResourceFrontend, name, f.Acls, frontendConditions(&f), add)
+	}
+
+	for _, b := range state.Backends {
+		name := b.Backend.Name
+		servers := map[string]bool{}
+		for _, server := range b.Servers {
+			servers[server.Name] = true
+		}
+		for i, rule := range b.ServerSwitchingRules {
+			if !servers[rule.TargetServer] {
+				add(LintError, LintDanglingUseServer, ResourceBackend, name, rule.TargetServer, "server switching rule %d uses the unknown server %s", i, rule.TargetServer)
+			}
+		}
+		lintAcls(ResourceBackend, name, b.Acls, backendConditions(&b), add)
+		// a computed backend may be any of them, the unused ones are only reported as info
+		if !routed[name] && len(dynamicRoutes) > 0 {
+			add(LintInfo, LintUnusedBackend, ResourceBackend, name, name, "no frontend routes to backend %s by name, frontend %s routes to a computed backend", name, dynamicRoutes[0])
+		} else if !routed[name] {
+			add(LintWarning, LintUnusedBackend, ResourceBackend, name, name, "no frontend routes to backend %s", name)
+		}
+	}
+	return &report
+}
+
+// the conditions of a parent, keyed by the rule using them and its position in the list (eg: "http request rule 2")
+type lintCondition struct {
+	rule     string
+	condTest string
+}
+
+func lintAcls(parentType string, parentName string, acls []HaproxyAddAcl, conditions []lintCondition, add func(LintSeverity, string, string, string, string, string, ...interface{})) {
+	defined := map[string]bool{}
+	for _, acl := range acls {
+		defined[acl.AclName] = true
+		if err := validateFetch(acl.Criterion); err != nil {
+			add(LintError, LintInvalidAcl, parentType, parentName, acl.AclName, "acl %s: %v", acl.AclName, err)
+		} else if !KnownFetch(acl.Criterion) {
+			add(LintWarning, LintUnknownFetch, parentType, parentName, acl.AclName, "acl %s uses the unknown fetch %s", acl.AclName, fetchName(acl.Criterion))
+		}
+	}
+	used := map[string]bool{}
+	for _, condition := range conditions {
+		if condition.condTest == "" {
+			continue
+		}
+		parsed, err := ParseCondition(condition.condTest)
+		if err != nil {
+			add(LintError, LintInvalidCondition, parentType, parentName, condition.rule, "%s: %v", condition.rule, err)
+			continue
+		}
+		for _, acl := range parsed.AclNames() {
+			used[acl] = true
+			if !defined[acl] && !predefinedAcls[acl] {
+				add(LintError, LintUndefinedAcl, parentType, parentName, acl, "%s uses the undefined acl %s", condition.rule, acl)
+			}
+		}
+		for _, terms := range parsed.Or {
+			for _, term := range terms {
+				if term.Anonymous != nil && !KnownFetch(term.Anonymous.Fetch) {
+					add(LintWarning, LintUnknownFetch, parentType, parentName, condition.rule, "%s uses the unknown fetch %s", condition.rule, fetchName(term.Anonymous.Fetch))
+				}
+			}
+		}
+	}
+	reported := map[string]bool{}
+	for _, acl := range acls {
+		if !used[acl.AclName] && !reported[acl.AclName] {
+			reported[acl.AclName] = true
+			add(LintInfo, LintUnusedAcl, parentType, parentName, acl.AclName, "acl %s is not used by any condition of %s %s", acl.AclName, parentType, parentName)
+		}
+	}
+}
+
+func frontendConditions(f *HaproxyDesiredFrontend) []lintCondition {
+	conditions := []lintCondition{}
+	for i, rule := range f.HttpRequestRules {
+		conditions = append(conditions, lintCondition{fmt.Sprintf("http request rule %d", i), rule.CondTest})
+	}
+	for i, rule := range f.HttpResponseRules {
+		conditions = append(conditions, lintCondition{fmt.Sprintf("http response rule %d", i), rule.CondTest})
+	}
+	for i, rule := range f.TcpRequestRules {
+		conditions = append(conditions, lintCondition{fmt.Sprintf("tcp request rule %d", i), rule.CondTest})
+	}
+	for i, rule := range f.BackendSwitchingRules {
+		conditions = append(conditions, lintCondition{fmt.Sprintf("backend switching rule %d", i), rule.CondTest})
+	}
+	return conditions
+}
+
+func backendConditions(b *HaproxyDesiredBackend) []lintCondition {
+	conditions := []lintCondition{}
+	for i, rule := range b.HttpRequestRules {
+		conditions = append(conditions, lintCondition{fmt.Sprintf("http request rule %d", i), rule.CondTest})
+	}
+	for i, rule := range b.HttpResponseRules {
+		conditions = append(conditions, lintCondition{fmt.Sprintf("http response rule %d", i), rule.CondTest})
+	}
+	for i, rule := range b.TcpRequestRules {
+		conditions = append(conditions, lintCondition{fmt.Sprintf("tcp request rule %d", i), rule.CondTest})
+	}
+	for i, rule := range b.TcpResponseRules {
+		conditions = append(conditions, lintCondition{fmt.Sprintf("tcp response rule %d", i), rule.CondTest})
+	}
+	for i, rule := range b.ServerSwitchingRules {
+		conditions = append(conditions, lintCondition{fmt.Sprintf("server switching rule %d", i), rule.CondTest})
+	}
+	return conditions
+}
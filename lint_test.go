@@ -0,0 +1,152 @@
+package haproxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLintState(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		findings []string // severity check parent_type/parent_name name
+		errors   bool
+	}{
+		{
+			"clean",
+			`
+backends:
+  - backend: {name: app}
+    servers: [{name: app1, address: 10.0.0.1, port: 8080}]
+  - backend: {name: api}
+    servers: [{name: api1, address: 10.0.0.2, port: 8080}]
+frontends:
+  - frontend: {name: www, default_backend: app}
+    acls: [{acl_name: is_api, criterion: path_beg, value: /api}]
+    backend_switching_rules: [{name: api, cond: if, cond_test: is_api || METH_POST}]
+`,
+			[]string{}, false,
+		},
+		{
+			"dangling references",
+			`
+backends:
+  - backend: {name: app}
+    servers: [{name: app1, address: 10.0.0.1, port: 8080}]
+    server_switching_rules: [{target_server: app9, cond: if, cond_test: "TRUE"}]
+frontends:
+  - frontend: {name: www, default_backend: missing}
+    backend_switching_rules:
+      - {name: app, cond: if, cond_test: is_api}
+      - {name: "%[req.hdr(host),lower]"}
+`,
+			[]string{
+				"error dangling_default_backend frontend/www missing",
+				"error undefined_acl frontend/www is_api",
+				"error dangling_use_server backend/app app9",
+			},
+			true,
+		},
+		{
+			"unused and empty",
+			`
+backends:
+  - backend: {name: app}
+  - backend: {name: spare}
+    servers: [{name: spare1, address: 10.0.0.1, port: 8080}]
+frontends:
+  - frontend: {name: www, default_backend: app}
+    acls: [{acl_name: is_api, criterion: path_beg, value: /api}]
+`,
+			[]string{
+				"warning empty_backend backend/app app",
+				"info unused_acl frontend/www is_api",
+				"warning unused_backend backend/spare spare",
+			},
+			false,
+		},
+		{
+			"unknown fetches are warnings",
+			`
+backends:
+  - backend: {name: app}
+    servers: [{name: app1, address: 10.0.0.1, port: 8080}]
+frontends:
+  - frontend: {name: www, default_backend: app}
+    acls: [{acl_name: is_new, criterion: fetch_of_a_future_version, value: "1"}]
+    backend_switching_rules: [{name: app, cond: if, cond_test: "is_new { other_future_fetch }"}]
+`,
+			[]string{
+				"warning unknown_fetch frontend/www is_new",
+				"warning unknown_fetch frontend/www backend switching rule 0",
+			},
+			false,
+		},
+		{
+			"unused backends of a computed route",
+			`
+backends:
+  - backend: {name: app}
+    servers: [{name: app1, address: 10.0.0.1, port: 8080}]
+  - backend: {name: tenant_a}
+    servers: [{name: a1, address: 10.0.0.2, port: 8080}]
+frontends:
+  - frontend: {name: www, default_backend: app}
+    backend_switching_rules: [{name: "tenant_%[req.hdr(x-tenant),lower]", cond: if, cond_test: "{ req.hdr(x-tenant) -m found }"}]
+`,
+			[]string{"info unused_backend backend/tenant_a tenant_a"},
+			false,
+		},
+		{
+			"rules named by their position",
+			`
+backends:
+  - backend: {name: app}
+    servers: [{name: app1, address: 10.0.0.1, port: 8080}]
+frontends:
+  - frontend: {name: www, default_backend: app}
+    acls: [{acl_name: is_api, criterion: path_beg, value: /api}]
+    http_request_rules:
+      - {index: 5, type: deny, cond: if, cond_test: is_api}
+      - {index: 5, type: deny, cond: if, cond_test: "{ future_fetch }"}
+`,
+			[]string{"warning unknown_fetch frontend/www http request rule 1"},
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := LintState(parseState(t, test.document))
+			findings := []string{}
+			for _, finding := range report.Findings {
+				findings = append(findings, string(finding.Severity)+" "+finding.Check+" "+finding.ParentType+"/"+finding.ParentName+" "+finding.Name)
+			}
+			if !reflect.DeepEqual(findings, test.findings) {
+				t.Fatalf("findings %q, want %q", findings, test.findings)
+			}
+			if report.HasErrors() != test.errors {
+				t.Fatalf("HasErrors %v, want %v", report.HasErrors(), test.errors)
+			}
+		})
+	}
+}
+
+func TestLintStateInvalid(t *testing.T) {
+	// states built in code skip the document validation
+	state := &HaproxyDesiredState{
+		Backends: []HaproxyDesiredBackend{{Backend: HaproxyAddBackend{Name: "app"}, Servers: []HaproxyAddServer{{Name: "app1", Address: "10.0.0.1"}}}},
+		Frontends: []HaproxyDesiredFrontend{{
+			Frontend:              HaproxyAddFrontend{Name: "www", DefaultBackend: "app"},
+			Acls:                  []HaproxyAddAcl{{AclName: "broken", Criterion: "hdr(host"}},
+			BackendSwitchingRules: []HaproxyAddBackendSwitchingRule{{Name: "app", Cond: CondIf, CondTest: "broken ||"}},
+		}},
+	}
+	findings := []string{}
+	for _, finding := range LintState(state).Findings {
+		findings = append(findings, finding.Check+" "+finding.Name)
+	}
+	want := []string{"invalid_acl broken", "invalid_condition backend switching rule 0", "unused_acl broken"}
+	if !reflect.DeepEqual(findings, want) {
+		t.Fatalf("findings %q, want %q", findings, want)
+	}
+}
@@ -29,6 +29,13 @@ SOFTWARE.
 
 package haproxy
 
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
 type HaproxyErrorResponse struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -81,57 +88,78 @@ type HaproxySites struct {
 	} `json:"data"`
 }
 
-type HaproxyStats []struct {
-	Error      string `json:"error"`
-	RuntimeAPI string `json:"runtimeAPI"`
-	Stats      []struct {
-		BackendName string `json:"backend_name"`
-		Name        string `json:"name"`
-		Stats       struct {
-			Bin         int    `json:"bin"`
-			Bout        int    `json:"bout"`
-			CompByp     int    `json:"comp_byp"`
-			CompIn      int    `json:"comp_in"`
-			CompOut     int    `json:"comp_out"`
-			CompRsp     int    `json:"comp_rsp"`
-			ConnRate    int    `json:"conn_rate"`
-			ConnRateMax int    `json:"conn_rate_max"`
-			ConnTot     int    `json:"conn_tot"`
-			Dcon        int    `json:"dcon"`
-			Dreq        int    `json:"dreq"`
-			Dresp       int    `json:"dresp"`
-			Dses        int    `json:"dses"`
-			Ereq        int    `json:"ereq"`
-			Hrsp1Xx     int    `json:"hrsp_1xx"`
-			Hrsp2Xx     int    `json:"hrsp_2xx"`
-			Hrsp3Xx     int    `json:"hrsp_3xx"`
-			Hrsp4Xx     int    `json:"hrsp_4xx"`
-			Hrsp5Xx     int    `json:"hrsp_5xx"`
-			HrspOther   int    `json:"hrsp_other"`
-			Iid         int    `json:"iid"`
-			Intercepted int    `json:"intercepted"`
-			Mode        string `json:"mode"`
-			Pid         int    `json:"pid"`
-			Rate        int    `json:"rate"`
-			RateLim     int    `json:"rate_lim"`
-			RateMax     int    `json:"rate_max"`
-			ReqRate     int    `json:"req_rate"`
-			ReqRateMax  int    `json:"req_rate_max"`
-			ReqTotal    int    `json:"req_total"`
-			Scur        int    `json:"scur"`
-			Slim        int    `json:"slim"`
-			Smax        int    `json:"smax"`
-			Status      string `json:"status"`
-			Stot        int    `json:"stot"`
-		} `json:"stats"`
-		Type string `json:"type"`
-	} `json:"stats"`
+// the native stats, one collection per runtime api (process)
+type HaproxyStats []HaproxyNativeStats
+
+type HaproxyNativeStats struct {
+	Error      string              `json:"error"`
+	RuntimeAPI string              `json:"runtimeAPI"`
+	Stats      []HaproxyNativeStat `json:"stats"`
+}
+
+// Type is frontend, backend or server, BackendName is only set for servers
+type HaproxyNativeStat struct {
+	BackendName string                  `json:"backend_name"`
+	Name        string                  `json:"name"`
+	Stats       HaproxyNativeStatValues `json:"stats"`
+	Type        string                  `json:"type"`
+}
+
+type HaproxyNativeStatValues struct {
+	Bin         int    `json:"bin"`
+	Bout        int    `json:"bout"`
+	CompByp     int    `json:"comp_byp"`
+	CompIn      int    `json:"comp_in"`
+	CompOut     int    `json:"comp_out"`
+	CompRsp     int    `json:"comp_rsp"`
+	ConnRate    int    `json:"conn_rate"`
+	ConnRateMax int    `json:"conn_rate_max"`
+	ConnTot     int    `json:"conn_tot"`
+	Dcon        int    `json:"dcon"`
+	Dreq        int    `json:"dreq"`
+	Dresp       int    `json:"dresp"`
+	Dses        int    `json:"dses"`
+	Econ        int    `json:"econ"`
+	Ereq        int    `json:"ereq"`
+	Eresp       int    `json:"eresp"`
+	Hrsp1Xx     int    `json:"hrsp_1xx"`
+	Hrsp2Xx     int    `json:"hrsp_2xx"`
+	Hrsp3Xx     int    `json:"hrsp_3xx"`
+	Hrsp4Xx     int    `json:"hrsp_4xx"`
+	Hrsp5Xx     int    `json:"hrsp_5xx"`
+	HrspOther   int    `json:"hrsp_other"`
+	Iid         int    `json:"iid"`
+	Intercepted int    `json:"intercepted"`
+	Mode        string `json:"mode"`
+	Pid         int    `json:"pid"`
+	Qcur        int    `json:"qcur"`
+	Rate        int    `json:"rate"`
+	RateLim     int    `json:"rate_lim"`
+	RateMax     int    `json:"rate_max"`
+	ReqRate     int    `json:"req_rate"`
+	ReqRateMax  int    `json:"req_rate_max"`
+	ReqTotal    int    `json:"req_total"`
+	Scur        int    `json:"scur"`
+	Slim        int    `json:"slim"`
+	Smax        int    `json:"smax"`
+	Status      string `json:"status"`
+	Stot        int    `json:"stot"`
+	Act         int    `json:"act"`
+	Weight      int    `json:"weight"`
 }
+
 type HaproxyReloads []struct {
 	ID     string `json:"id"`
 	Status string `json:"status"`
 }
 
+type HaproxyReload struct {
+	ID              string `json:"id"`
+	Status          string `json:"status"`
+	ReloadTimestamp int    `json:"reload_timestamp,omitempty"`
+	Response        string `json:"response,omitempty"` // the haproxy output of a failed reload
+}
+
 type HaproxyTransactions []struct {
 	Version int    `json:"_version"`
 	ID      string `json:"id"`
@@ -144,6 +172,11 @@ type HaproxyTransaction struct {
 	Status  string `json:"status"`
 }
 
+type HaproxyRawConfiguration struct {
+	Version int    `json:"_version"`
+	Data    string `json:"data"`
+}
+
 type HaproxyConfigurationGlobal struct {
 	Version int `json:"_version"`
 	Data    struct {
@@ -194,6 +227,130 @@ type HaproxyConfigurationDefaults struct {
 	} `json:"data"`
 }
 
+// proxy mode of frontends, backends and defaults
+type ProxyMode string
+
+const (
+	ModeHTTP ProxyMode = "http"
+	ModeTCP  ProxyMode = "tcp"
+)
+
+// load balancing algorithm of backends and defaults
+type BalanceAlgorithm string
+
+const (
+	BalanceRoundRobin BalanceAlgorithm = "roundrobin"
+	BalanceStaticRR   BalanceAlgorithm = "static-rr"
+	BalanceLeastConn  BalanceAlgorithm = "leastconn"
+	BalanceFirst      BalanceAlgorithm = "first"
+	BalanceSource     BalanceAlgorithm = "source"
+	BalanceURI        BalanceAlgorithm = "uri"
+	BalanceURLParam   BalanceAlgorithm = "url_param"
+	BalanceHdr        BalanceAlgorithm = "hdr"
+	BalanceRandom     BalanceAlgorithm = "random"
+	BalanceRdpCookie  BalanceAlgorithm = "rdp-cookie"
+	BalanceHash       BalanceAlgorithm = "hash"
+)
+
+// health check of a server
+type ServerCheck string
+
+const (
+	CheckEnabled  ServerCheck = "enabled"
+	CheckDisabled ServerCheck = "disabled"
+)
+
+// advanced health check of a backend
+type AdvCheck string
+
+const (
+	AdvCheckHttpchk     AdvCheck = "httpchk"
+	AdvCheckSslHelloChk AdvCheck = "ssl-hello-chk"
+	AdvCheckSmtpchk     AdvCheck = "smtpchk"
+	AdvCheckLdapCheck   AdvCheck = "ldap-check"
+	AdvCheckMysqlCheck  AdvCheck = "mysql-check"
+	AdvCheckPgsqlCheck  AdvCheck = "pgsql-check"
+	AdvCheckTcpCheck    AdvCheck = "tcp-check"
+	AdvCheckRedisCheck  AdvCheck = "redis-check"
+)
+
+type HTTPConnectionMode string
+
+const (
+	HTTPConnectionClose       HTTPConnectionMode = "httpclose"
+	HTTPConnectionServerClose HTTPConnectionMode = "http-server-close"
+	HTTPConnectionKeepAlive   HTTPConnectionMode = "http-keep-alive"
+)
+
+type HttpRequestRuleType string
+
+// http-request actions most commonly used, Valid accepts every type of the dataplane api v2 spec
+const (
+	HttpRequestRuleAllow         HttpRequestRuleType = "allow"
+	HttpRequestRuleDeny          HttpRequestRuleType = "deny"
+	HttpRequestRuleRedirect      HttpRequestRuleType = "redirect"
+	HttpRequestRuleAddHeader     HttpRequestRuleType = "add-header"
+	HttpRequestRuleSetHeader     HttpRequestRuleType = "set-header"
+	HttpRequestRuleDelHeader     HttpRequestRuleType = "del-header"
+	HttpRequestRuleReplaceHeader HttpRequestRuleType = "replace-header"
+	HttpRequestRuleSetPath       HttpRequestRuleType = "set-path"
+	HttpRequestRuleReplacePath   HttpRequestRuleType = "replace-path"
+	HttpRequestRuleReturn        HttpRequestRuleType = "return"
+)
+
+type HttpResponseRuleType string
+
+type TcpRequestRuleType string
+
+type TcpResponseRuleType string
+
+type TcpRuleAction string
+
+// the options of the algorithm (hdr_name, url_param...) are kept as unmodeled fields
+type HaproxyBalance struct {
+	Algorithm BalanceAlgorithm `json:"algorithm"`
+	Arguments []string         `json:"arguments,omitempty"`
+	unmodeledFields
+}
+
+func (b *HaproxyBalance) UnmarshalJSON(data []byte) error {
+	type plain HaproxyBalance
+	return unmarshalModel(data, (*plain)(b), &b.unmodeledFields)
+}
+
+func (b HaproxyBalance) MarshalJSON() ([]byte, error) {
+	type plain HaproxyBalance
+	return marshalModel(plain(b), b.unmodeledFields)
+}
+
+// a named defaults section, frontends and backends inherit it through their From field
+type HaproxyAddNamedDefaults struct {
+	Name                 string             `json:"name"`
+	From                 string             `json:"from,omitempty"`
+	Mode                 ProxyMode          `json:"mode,omitempty"`
+	Balance              *HaproxyBalance    `json:"balance,omitempty"`
+	ClientTimeout        int                `json:"client_timeout,omitempty"`
+	ConnectTimeout       int                `json:"connect_timeout,omitempty"`
+	ServerTimeout        int                `json:"server_timeout,omitempty"`
+	QueueTimeout         int                `json:"queue_timeout,omitempty"`
+	HTTPKeepAliveTimeout int                `json:"http_keep_alive_timeout,omitempty"`
+	HTTPConnectionMode   HTTPConnectionMode `json:"http_connection_mode,omitempty"`
+	Maxconn              int                `json:"maxconn,omitempty"`
+	Httplog              bool               `json:"httplog,omitempty"`
+	Tcplog               bool               `json:"tcplog,omitempty"`
+	Dontlognull          string             `json:"dontlognull,omitempty"`
+}
+
+type HaproxyNamedDefaults struct {
+	Version int                       `json:"_version"`
+	Data    []HaproxyAddNamedDefaults `json:"data"`
+}
+
+type HaproxyNamedDefault struct {
+	Version int                     `json:"_version"`
+	Data    HaproxyAddNamedDefaults `json:"data"`
+}
+
 type HaproxyBackends struct {
 	Version int `json:"_version"`
 	Data    []struct {
@@ -203,6 +360,7 @@ type HaproxyBackends struct {
 		} `json:"balance,omitempty"`
 		Mode       string `json:"mode"`
 		Name       string `json:"name"`
+		From       string `json:"from,omitempty"`
 		Forwardfor struct {
 			Enabled string `json:"enabled"`
 		} `json:"forwardfor,omitempty"`
@@ -210,6 +368,11 @@ type HaproxyBackends struct {
 			Method string `json:"method"`
 			URI    string `json:"uri"`
 		} `json:"httpchk,omitempty"`
+		HttpchkParams struct {
+			Method  string `json:"method"`
+			URI     string `json:"uri"`
+			Version string `json:"version"`
+		} `json:"httpchk_params,omitempty"`
 		HTTPConnectionMode string `json:"http_connection_mode,omitempty"`
 		StickTable         struct {
 			Expire int    `json:"expire"`
@@ -230,100 +393,180 @@ type HaproxyBackends struct {
 type HaproxyFrontends struct {
 	Version int `json:"_version"`
 	Data    []struct {
-		DefaultBackend string `json:"default_backend,omitempty"`
-		Mode           string `json:"mode,omitempty"`
-		Name           string `json:"name"`
-		Tcplog         bool   `json:"tcplog,omitempty"`
-		Address 	   string `json:"address,omitempty"`
-		Port 	       int    `json:"port,omitempty"`
-		Check          string `json:"check,omitempty"`
-		Forwardfor     struct {
+		DefaultBackend     string `json:"default_backend,omitempty"`
+		Mode               string `json:"mode,omitempty"`
+		Name               string `json:"name"`
+		From               string `json:"from,omitempty"`
+		Tcplog             bool   `json:"tcplog,omitempty"`
+		Maxconn            int    `json:"maxconn,omitempty"`
+		HTTPConnectionMode string `json:"http_connection_mode,omitempty"`
+		Address            string `json:"address,omitempty"`
+		Port               int    `json:"port,omitempty"`
+		Check              string `json:"check,omitempty"`
+		Forwardfor         struct {
 			Enabled string `json:"enabled"`
 		} `json:"forwardfor,omitempty"`
 	} `json:"data"`
 }
 
+type HaproxyAddBind struct {
+	Name           string `json:"name"`
+	Address        string `json:"address"`
+	Port           int    `json:"port,omitempty"`
+	Ssl            bool   `json:"ssl,omitempty"`
+	SslCertificate string `json:"ssl_certificate,omitempty"`
+	Alpn           string `json:"alpn,omitempty"`
+	AcceptProxy    bool   `json:"accept_proxy,omitempty"`
+	V4v6           bool   `json:"v4v6,omitempty"`
+	Maxconn        int    `json:"maxconn,omitempty"`
+	unmodeledFields
+}
+
+func (b *HaproxyAddBind) UnmarshalJSON(data []byte) error {
+	type plain HaproxyAddBind
+	return unmarshalModel(data, (*plain)(b), &b.unmodeledFields)
+}
+
+func (b HaproxyAddBind) MarshalJSON() ([]byte, error) {
+	type plain HaproxyAddBind
+	return marshalModel(plain(b), b.unmodeledFields)
+}
+
+type HaproxyBinds struct {
+	Version int              `json:"_version"`
+	Data    []HaproxyAddBind `json:"data"`
+}
+
 type HaproxyBackendSwitchingRules struct {
-	Version int `json:"_version"`
-	Data    []struct {
-		Cond     string `json:"cond"`
-		CondTest string `json:"cond_test"`
-		Index    int    `json:"index"`
-		Name     string `json:"name"`
-	} `json:"data"`
+	Version int                              `json:"_version"`
+	Data    []HaproxyAddBackendSwitchingRule `json:"data"`
+}
+
+type HaproxyBackendSwitchingRule struct {
+	Version int                            `json:"_version"`
+	Data    HaproxyAddBackendSwitchingRule `json:"data"`
 }
 
 type HaproxyServers struct {
-	Version int `json:"_version"`
-	Data    []struct {
-		Address string `json:"address"`
-		Check   string `json:"check"`
-		Name    string `json:"name"`
-		Port    int    `json:"port"`
-		Weight  int    `json:"weight"`
-	} `json:"data"`
+	Version int                `json:"_version"`
+	Data    []HaproxyAddServer `json:"data"`
 }
 
 type HaproxyAcls struct {
-	Version int `json:"_version"`
-	Data    []struct {
-		AclName   string `json:"acl_name"`
-		Criterion string `json:"criterion"`
-		Index     int    `json:"index"`
-		Value     string `json:"value"`
-	} `json:"data"`
+	Version int             `json:"_version"`
+	Data    []HaproxyAddAcl `json:"data"`
+}
+
+type HaproxyAcl struct {
+	Version int           `json:"_version"`
+	Data    HaproxyAddAcl `json:"data"`
 }
 
 type HaproxyServerSwitchingRules struct {
-	Version int `json:"_version"`
-	Data    []struct {
-		Cond         string `json:"cond"`
-		CondTest     string `json:"cond_test"`
-		Index        int    `json:"index"`
-		TargetServer string `json:"target_server"`
-		ReturnHdrs   string `json:"return_hdrs"`
-		PathFmt      string `json:"path_fmt"`
-		Type         string `json:"type"`
-	} `json:"data"`
+	Version int                             `json:"_version"`
+	Data    []HaproxyAddServerSwitchingRule `json:"data"`
+}
+
+type HaproxyServerSwitchingRule struct {
+	Version int                           `json:"_version"`
+	Data    HaproxyAddServerSwitchingRule `json:"data"`
 }
 
 type HaproxyHttpRequestRules struct {
-	Version int `json:"_version"`
-	Data    []struct {
-		Cond       string  `json:"cond"`
-		CondTest   string  `json:"cond_test"`
-		HdrFormat  string  `json:"hdr_format"`
-		HdrName    string  `json:"hdr_name"`
-		Index      int     `json:"index"`
-		Type       string  `json:"type"`
-		ReturnHdrs *string `json:"return_hdrs"`
-		PathFmt    *string `json:"path_fmt"`
-	} `json:"data"`
+	Version int                         `json:"_version"`
+	Data    []HaproxyAddHttpRequestRule `json:"data"`
+}
+
+type HaproxyHttpRequestRule struct {
+	Version int                       `json:"_version"`
+	Data    HaproxyAddHttpRequestRule `json:"data"`
 }
 
 type HaproxyAddBackend struct {
-	AdvCheck string `json:"adv_check"`
-	Balance  struct {
-		Algorithm string `json:"algorithm"`
-	} `json:"balance"`
-	Forwardfor struct {
-		Enabled string `json:"enabled"`
-	} `json:"forwardfor"`
+	AdvCheck      AdvCheck          `json:"adv_check,omitempty"`
+	Balance       HaproxyBalance    `json:"balance"`
+	Forwardfor    HaproxyForwardfor `json:"forwardfor"`
 	HttpchkParams struct {
 		Method  string `json:"method"`
 		URI     string `json:"uri"`
 		Version string `json:"version"`
 	} `json:"httpchk_params"`
-	Mode string `json:"mode"`
-	Name string `json:"name"`
+	Mode ProxyMode `json:"mode,omitempty"`
+	Name string    `json:"name"`
+	From string    `json:"from,omitempty"`
+	unmodeledFields
+}
+
+type HaproxyBackend struct {
+	Version int               `json:"_version"`
+	Data    HaproxyAddBackend `json:"data"`
+}
+
+// except, header and ifnone are kept as unmodeled fields
+type HaproxyForwardfor struct {
+	Enabled string `json:"enabled"`
+	unmodeledFields
+}
+
+func (f *HaproxyForwardfor) UnmarshalJSON(data []byte) error {
+	type plain HaproxyForwardfor
+	return unmarshalModel(data, (*plain)(f), &f.unmodeledFields)
+}
+
+func (f HaproxyForwardfor) MarshalJSON() ([]byte, error) {
+	type plain HaproxyForwardfor
+	return marshalModel(plain(f), f.unmodeledFields)
+}
+
+func (b *HaproxyAddBackend) UnmarshalJSON(data []byte) error {
+	type plain HaproxyAddBackend
+	return unmarshalModel(data, (*plain)(b), &b.unmodeledFields)
+}
+
+// the nested sections left empty are omitted, the dataplane api rejects them otherwise
+func (b HaproxyAddBackend) MarshalJSON() ([]byte, error) {
+	type plain HaproxyAddBackend
+	body := struct {
+		plain
+		Balance       interface{} `json:"balance,omitempty"`
+		Forwardfor    interface{} `json:"forwardfor,omitempty"`
+		HttpchkParams interface{} `json:"httpchk_params,omitempty"`
+	}{plain: plain(b)}
+	if b.Balance.Algorithm != "" || len(b.Balance.Arguments) > 0 || len(b.Balance.fields) > 0 {
+		body.Balance = b.Balance
+	}
+	if b.Forwardfor.Enabled != "" || len(b.Forwardfor.fields) > 0 {
+		body.Forwardfor = b.Forwardfor
+	}
+	if b.HttpchkParams.Method != "" || b.HttpchkParams.URI != "" || b.HttpchkParams.Version != "" {
+		body.HttpchkParams = b.HttpchkParams
+	}
+	return marshalModel(body, b.unmodeledFields)
 }
 
 type HaproxyAddFrontend struct {
-	DefaultBackend     string `json:"default_backend"`
-	HTTPConnectionMode string `json:"http_connection_mode"`
-	Maxconn            int    `json:"maxconn"`
-	Mode               string `json:"mode"`
-	Name               string `json:"name"`
+	DefaultBackend     string             `json:"default_backend,omitempty"`
+	HTTPConnectionMode HTTPConnectionMode `json:"http_connection_mode,omitempty"`
+	Maxconn            int                `json:"maxconn,omitempty"`
+	Mode               ProxyMode          `json:"mode,omitempty"`
+	Name               string             `json:"name"`
+	From               string             `json:"from,omitempty"`
+	unmodeledFields
+}
+
+type HaproxyFrontend struct {
+	Version int                `json:"_version"`
+	Data    HaproxyAddFrontend `json:"data"`
+}
+
+func (f *HaproxyAddFrontend) UnmarshalJSON(data []byte) error {
+	type plain HaproxyAddFrontend
+	return unmarshalModel(data, (*plain)(f), &f.unmodeledFields)
+}
+
+func (f HaproxyAddFrontend) MarshalJSON() ([]byte, error) {
+	type plain HaproxyAddFrontend
+	return marshalModel(plain(f), f.unmodeledFields)
 }
 
 type HaproxyAddAcl struct {
@@ -331,30 +574,243 @@ type HaproxyAddAcl struct {
 	Criterion string `json:"criterion"`
 	Index     int    `json:"index"`
 	Value     string `json:"value"`
+	unmodeledFields
+}
+
+func (a *HaproxyAddAcl) UnmarshalJSON(data []byte) error {
+	type plain HaproxyAddAcl
+	return unmarshalModel(data, (*plain)(a), &a.unmodeledFields)
+}
+
+func (a HaproxyAddAcl) MarshalJSON() ([]byte, error) {
+	type plain HaproxyAddAcl
+	return marshalModel(plain(a), a.unmodeledFields)
 }
 
 type HaproxyAddServer struct {
-	Address string `json:"address"`
-	Check   string `json:"check"`
-	Name    string `json:"name"`
-	Port    int    `json:"port"`
-	Weight  int    `json:"weight"`
+	Address string      `json:"address"`
+	Check   ServerCheck `json:"check,omitempty"`
+	Name    string      `json:"name"`
+	Port    int         `json:"port,omitempty"`
+	Weight  *int        `json:"weight,omitempty"` // nil leaves the haproxy default, 1
+	unmodeledFields
+}
+
+func (s *HaproxyAddServer) UnmarshalJSON(data []byte) error {
+	type plain HaproxyAddServer
+	return unmarshalModel(data, (*plain)(s), &s.unmodeledFields)
+}
+
+func (s HaproxyAddServer) MarshalJSON() ([]byte, error) {
+	type plain HaproxyAddServer
+	return marshalModel(plain(s), s.unmodeledFields)
 }
 
 type HaproxyAddHttpRequestRule struct {
-	Cond      string `json:"cond"`
-	CondTest  string `json:"cond_test"`
-	HdrFormat string `json:"hdr_format"`
-	HdrName   string `json:"hdr_name"`
-	Index     int    `json:"index"`
-	Type      string `json:"type"`
+	Cond      string              `json:"cond,omitempty"`
+	CondTest  string              `json:"cond_test,omitempty"`
+	HdrFormat string              `json:"hdr_format,omitempty"`
+	HdrName   string              `json:"hdr_name,omitempty"`
+	Index     int                 `json:"index"`
+	Type      HttpRequestRuleType `json:"type"`
+	// replace-path, set-path
+	PathMatch string `json:"path_match,omitempty"`
+	PathFmt   string `json:"path_fmt,omitempty"`
+	unmodeledFields
+}
+
+func (r *HaproxyAddHttpRequestRule) UnmarshalJSON(data []byte) error {
+	type plain HaproxyAddHttpRequestRule
+	return unmarshalModel(data, (*plain)(r), &r.unmodeledFields)
+}
+
+func (r HaproxyAddHttpRequestRule) MarshalJSON() ([]byte, error) {
+	type plain HaproxyAddHttpRequestRule
+	return marshalModel(plain(r), r.unmodeledFields)
+}
+
+// http-response actions most commonly used, Valid accepts every type of the dataplane api v2 spec
+const (
+	HttpResponseRuleAllow         HttpResponseRuleType = "allow"
+	HttpResponseRuleDeny          HttpResponseRuleType = "deny"
+	HttpResponseRuleRedirect      HttpResponseRuleType = "redirect"
+	HttpResponseRuleAddHeader     HttpResponseRuleType = "add-header"
+	HttpResponseRuleSetHeader     HttpResponseRuleType = "set-header"
+	HttpResponseRuleDelHeader     HttpResponseRuleType = "del-header"
+	HttpResponseRuleReplaceHeader HttpResponseRuleType = "replace-header"
+	HttpResponseRuleSetStatus     HttpResponseRuleType = "set-status"
+	HttpResponseRuleReturn        HttpResponseRuleType = "return"
+)
+
+type HaproxyAddHttpResponseRule struct {
+	Index    int                  `json:"index"`
+	Type     HttpResponseRuleType `json:"type"`
+	Cond     string               `json:"cond,omitempty"`
+	CondTest string               `json:"cond_test,omitempty"`
+	// add-header, set-header, del-header, replace-header
+	HdrName   string `json:"hdr_name,omitempty"`
+	HdrFormat string `json:"hdr_format,omitempty"`
+	HdrMatch  string `json:"hdr_match,omitempty"`
+	HdrMethod string `json:"hdr_method,omitempty"`
+	// set-status
+	Status       int    `json:"status,omitempty"`
+	StatusReason string `json:"status_reason,omitempty"`
+	// deny
+	DenyStatus int `json:"deny_status,omitempty"`
+	// redirect (type: location, prefix or scheme)
+	RedirType   string `json:"redir_type,omitempty"`
+	RedirValue  string `json:"redir_value,omitempty"`
+	RedirCode   int    `json:"redir_code,omitempty"`
+	RedirOption string `json:"redir_option,omitempty"`
+	// return
+	ReturnStatusCode    int    `json:"return_status_code,omitempty"`
+	ReturnContentType   string `json:"return_content_type,omitempty"`
+	ReturnContent       string `json:"return_content,omitempty"`
+	ReturnContentFormat string `json:"return_content_format,omitempty"`
+	unmodeledFields
+}
+
+func (r *HaproxyAddHttpResponseRule) UnmarshalJSON(data []byte) error {
+	type plain HaproxyAddHttpResponseRule
+	return unmarshalModel(data, (*plain)(r), &r.unmodeledFields)
+}
+
+func (r HaproxyAddHttpResponseRule) MarshalJSON() ([]byte, error) {
+	type plain HaproxyAddHttpResponseRule
+	return marshalModel(plain(r), r.unmodeledFields)
+}
+
+type HaproxyHttpResponseRules struct {
+	Version int                          `json:"_version"`
+	Data    []HaproxyAddHttpResponseRule `json:"data"`
+}
+
+type HaproxyHttpResponseRule struct {
+	Version int                        `json:"_version"`
+	Data    HaproxyAddHttpResponseRule `json:"data"`
+}
+
+// tcp-request rule types
+const (
+	TcpRequestRuleConnection   TcpRequestRuleType = "connection"
+	TcpRequestRuleContent      TcpRequestRuleType = "content"
+	TcpRequestRuleSession      TcpRequestRuleType = "session"
+	TcpRequestRuleInspectDelay TcpRequestRuleType = "inspect-delay"
+)
+
+// tcp-response rule types
+const (
+	TcpResponseRuleContent      TcpResponseRuleType = "content"
+	TcpResponseRuleInspectDelay TcpResponseRuleType = "inspect-delay"
+)
+
+// tcp rule actions most commonly used
+const (
+	TcpRuleActionAccept TcpRuleAction = "accept"
+	TcpRuleActionReject TcpRuleAction = "reject"
+	TcpRuleActionSetVar TcpRuleAction = "set-var"
+)
+
+type HaproxyAddTcpRequestRule struct {
+	Index    int                `json:"index"`
+	Type     TcpRequestRuleType `json:"type"`
+	Action   TcpRuleAction      `json:"action,omitempty"`
+	Cond     string             `json:"cond,omitempty"`
+	CondTest string             `json:"cond_test,omitempty"`
+	// inspect-delay only, in milliseconds
+	Timeout int `json:"timeout,omitempty"`
+	// set-var only
+	VarScope string `json:"var_scope,omitempty"`
+	VarName  string `json:"var_name,omitempty"`
+	Expr     string `json:"expr,omitempty"`
+	unmodeledFields
+}
+
+func (r *HaproxyAddTcpRequestRule) UnmarshalJSON(data []byte) error {
+	type plain HaproxyAddTcpRequestRule
+	return unmarshalModel(data, (*plain)(r), &r.unmodeledFields)
+}
+
+func (r HaproxyAddTcpRequestRule) MarshalJSON() ([]byte, error) {
+	type plain HaproxyAddTcpRequestRule
+	return marshalModel(plain(r), r.unmodeledFields)
+}
+
+type HaproxyTcpRequestRules struct {
+	Version int                        `json:"_version"`
+	Data    []HaproxyAddTcpRequestRule `json:"data"`
+}
+
+type HaproxyTcpRequestRule struct {
+	Version int                      `json:"_version"`
+	Data    HaproxyAddTcpRequestRule `json:"data"`
+}
+
+type HaproxyAddTcpResponseRule struct {
+	Index    int                 `json:"index"`
+	Type     TcpResponseRuleType `json:"type"`
+	Action   TcpRuleAction       `json:"action,omitempty"`
+	Cond     string              `json:"cond,omitempty"`
+	CondTest string              `json:"cond_test,omitempty"`
+	// inspect-delay only, in milliseconds
+	Timeout int `json:"timeout,omitempty"`
+	unmodeledFields
+}
+
+func (r *HaproxyAddTcpResponseRule) UnmarshalJSON(data []byte) error {
+	type plain HaproxyAddTcpResponseRule
+	return unmarshalModel(data, (*plain)(r), &r.unmodeledFields)
+}
+
+func (r HaproxyAddTcpResponseRule) MarshalJSON() ([]byte, error) {
+	type plain HaproxyAddTcpResponseRule
+	return marshalModel(plain(r), r.unmodeledFields)
+}
+
+type HaproxyTcpResponseRules struct {
+	Version int                         `json:"_version"`
+	Data    []HaproxyAddTcpResponseRule `json:"data"`
+}
+
+type HaproxyTcpResponseRule struct {
+	Version int                       `json:"_version"`
+	Data    HaproxyAddTcpResponseRule `json:"data"`
 }
 
 type HaproxyAddBackendSwitchingRule struct {
-	Cond     string `json:"cond"`
-	CondTest string `json:"cond_test"`
+	Cond     string `json:"cond,omitempty"`
+	CondTest string `json:"cond_test,omitempty"`
 	Index    int    `json:"index"`
 	Name     string `json:"name"`
+	unmodeledFields
+}
+
+func (r *HaproxyAddBackendSwitchingRule) UnmarshalJSON(data []byte) error {
+	type plain HaproxyAddBackendSwitchingRule
+	return unmarshalModel(data, (*plain)(r), &r.unmodeledFields)
+}
+
+func (r HaproxyAddBackendSwitchingRule) MarshalJSON() ([]byte, error) {
+	type plain HaproxyAddBackendSwitchingRule
+	return marshalModel(plain(r), r.unmodeledFields)
+}
+
+type HaproxyAddServerSwitchingRule struct {
+	Cond         string `json:"cond,omitempty"`
+	CondTest     string `json:"cond_test,omitempty"`
+	Index        int    `json:"index"`
+	TargetServer string `json:"target_server"`
+	unmodeledFields
+}
+
+func (r *HaproxyAddServerSwitchingRule) UnmarshalJSON(data []byte) error {
+	type plain HaproxyAddServerSwitchingRule
+	return unmarshalModel(data, (*plain)(r), &r.unmodeledFields)
+}
+
+func (r HaproxyAddServerSwitchingRule) MarshalJSON() ([]byte, error) {
+	type plain HaproxyAddServerSwitchingRule
+	return marshalModel(plain(r), r.unmodeledFields)
 }
 
 type HaproxyCommitTransaction struct {
@@ -363,9 +819,12 @@ type HaproxyCommitTransaction struct {
 	Status  string `json:"status"`
 }
 
+// ParentType and ParentName are set for the acls and servers, which are defined per parent
 type DuplicateCount struct {
-	Name  string `json:"name"`
-	Count int    `json:"count"`
+	Name       string `json:"name"`
+	Count      int    `json:"count"`
+	ParentType string `json:"parent_type,omitempty"`
+	ParentName string `json:"parent_name,omitempty"`
 }
 type HaproxyDuplicateDefinitionsResult struct {
 	Acls      []DuplicateCount `json:"acls"`
@@ -373,3 +832,96 @@ type HaproxyDuplicateDefinitionsResult struct {
 	Frontends []DuplicateCount `json:"frontends"`
 	Servers   []DuplicateCount `json:"servers"`
 }
+
+// the fields of a dataplane object its model has no field for (ssl, maxconn, timeouts...), kept as
+// they were read and sent back, so that replacing an object read from the dataplane api does not reset them
+type unmodeledFields struct {
+	fields map[string]json.RawMessage
+}
+
+func (u *unmodeledFields) unmodeled() *unmodeledFields {
+	return u
+}
+
+// add the unmodeled fields of current that are not set on u
+func (u *unmodeledFields) keep(current *unmodeledFields) {
+	if len(current.fields) == 0 {
+		return
+	}
+	fields := map[string]json.RawMessage{}
+	for name, value := range current.fields {
+		fields[name] = value
+	}
+	for name, value := range u.fields {
+		fields[name] = value
+	}
+	u.fields = fields
+}
+
+// implemented by the models keeping their unmodeled fields
+type unmodeledModel interface {
+	unmodeled() *unmodeledFields
+}
+
+// decode data into model, a pointer to a struct, and keep aside the keys matching none of its fields
+func unmarshalModel(data []byte, model interface{}, unmodeled *unmodeledFields) error {
+	if err := json.Unmarshal(data, model); err != nil {
+		return err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, name := range jsonFieldNames(reflect.TypeOf(model).Elem()) {
+		delete(fields, name)
+	}
+	unmodeled.fields = nil
+	for name, value := range fields {
+		compact := bytes.Buffer{}
+		if err := json.Compact(&compact, value); err != nil {
+			return err
+		}
+		if unmodeled.fields == nil {
+			unmodeled.fields = map[string]json.RawMessage{}
+		}
+		unmodeled.fields[name] = compact.Bytes()
+	}
+	return nil
+}
+
+// encode model and add the unmodeled fields to it
+func marshalModel(model interface{}, unmodeled unmodeledFields) ([]byte, error) {
+	data, err := json.Marshal(model)
+	if err != nil || len(unmodeled.fields) == 0 {
+		return data, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range unmodeled.fields {
+		if _, modeled := fields[name]; !modeled {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+func jsonFieldNames(t reflect.Type) []string {
+	names := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		switch {
+		case name == "-":
+		case field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct:
+			names = append(names, jsonFieldNames(field.Type)...)
+		case !field.IsExported():
+		case name == "":
+			names = append(names, field.Name)
+		default:
+			names = append(names, name)
+		}
+	}
+	return names
+}
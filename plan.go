@@ -0,0 +1,184 @@
+package haproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a field changed by a HaproxyChange, Path follows the dataplane json names (eg: balance.algorithm).
+// Current is nil for created fields, Desired is nil for removed ones.
+type HaproxyFieldDiff struct {
+	Path    string      `json:"path"`
+	Current interface{} `json:"current,omitempty"`
+	Desired interface{} `json:"desired,omitempty"`
+}
+
+// the changes needed to reach a desired state, computed against the configuration Version
+type HaproxyPlan struct {
+	Version int             `json:"version"`
+	Changes []HaproxyChange `json:"changes"`
+}
+
+// compute the changes needed to reach the desired state without writing anything
+func (h *haproxyClient) Plan(desired *HaproxyDesiredState) (*HaproxyPlan, error) {
+	// the version is read first so that applying the plan fails if the configuration changes meanwhile
+	version, err := h.GetConfigurationVersion()
+	if err != nil {
+		return nil, err
+	}
+	live, err := h.fetchState()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := diffStates(live, desired)
+	if err != nil {
+		return nil, err
+	}
+	return &HaproxyPlan{Version: *version, Changes: changes}, nil
+}
+
+// apply a plan returned by Plan in a single transaction, the commit is refused by the dataplane api
+// if the configuration changed since the plan was computed.
+//
+// the transaction is deleted when a change fails, the error tells when it could not be.
+// plans decoded from json can be reviewed but not applied.
+func (h *haproxyClient) ApplyPlan(plan *HaproxyPlan) error {
+	if !plan.HasChanges() {
+		return nil
+	}
+	for _, change := range plan.Changes {
+		if change.apply == nil {
+			return errors.New("the plan was not computed by Plan and can't be applied")
+		}
+	}
+	transactionId, err := h.StartTransaction(strconv.Itoa(plan.Version))
+	if err != nil {
+		return err
+	}
+	for _, change := range plan.Changes {
+		if err := change.apply(h, *transactionId); err != nil {
+			if deleteErr := h.DeleteTransaction(*transactionId); deleteErr != nil {
+				return fmt.Errorf("%s: %w (the transaction %s is left: %v)", change, err, *transactionId, deleteErr)
+			}
+			return fmt.Errorf("%s: %w", change, err)
+		}
+	}
+	return h.CommitTransaction(*transactionId)
+}
+
+func (p *HaproxyPlan) HasChanges() bool {
+	return len(p.Changes) > 0
+}
+
+// human readable plan: one line per change prefixed by +, ~ or - (create, update, delete)
+// followed by the changed fields, and a summary line.
+func (p *HaproxyPlan) String() string {
+	if !p.HasChanges() {
+		return "No changes, the configuration is up to date.\n"
+	}
+	var b strings.Builder
+	counts := map[HaproxyChangeAction]int{}
+	for _, change := range p.Changes {
+		counts[change.Action]++
+		switch change.Action {
+		case ChangeCreate:
+			b.WriteString("+ ")
+		case ChangeUpdate:
+			b.WriteString("~ ")
+		case ChangeDelete:
+			b.WriteString("- ")
+		}
+		b.WriteString(change.String() + "\n")
+		if change.Action == ChangeDelete {
+			continue
+		}
+		for _, field := range change.Fields {
+			if change.Action == ChangeCreate {
+				fmt.Fprintf(&b, "    %s: %s\n", field.Path, planValue(field.Desired))
+			} else {
+				fmt.Fprintf(&b, "    %s: %s -> %s\n", field.Path, planValue(field.Current), planValue(field.Desired))
+			}
+		}
+	}
+	fmt.Fprintf(&b, "\nPlan: %d to create, %d to update, %d to delete.\n", counts[ChangeCreate], counts[ChangeUpdate], counts[ChangeDelete])
+	return b.String()
+}
+
+func planValue(value interface{}) string {
+	if value == nil {
+		return "(none)"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// compare two models field by field through their json representation, nil stands for a missing model.
+// The rules Index is left out since it is carried by the change itself.
+func fieldDiffs(current interface{}, desired interface{}) []HaproxyFieldDiff {
+	currentFields := flattenModel(current)
+	desiredFields := flattenModel(desired)
+	paths := []string{}
+	for path := range currentFields {
+		paths = append(paths, path)
+	}
+	for path := range desiredFields {
+		if _, exist := currentFields[path]; !exist {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	diffs := []HaproxyFieldDiff{}
+	for _, path := range paths {
+		if path == "index" || reflect.DeepEqual(currentFields[path], desiredFields[path]) {
+			continue
+		}
+		diffs = append(diffs, HaproxyFieldDiff{Path: path, Current: currentFields[path], Desired: desiredFields[path]})
+	}
+	return diffs
+}
+
+func flattenModel(model interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if model == nil || (reflect.ValueOf(model).Kind() == reflect.Ptr && reflect.ValueOf(model).IsNil()) {
+		return fields
+	}
+	data, err := json.Marshal(model)
+	if err != nil {
+		return fields
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fields
+	}
+	flattenValue("", value, fields)
+	return fields
+}
+
+func flattenValue(path string, value interface{}, fields map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if path == "" {
+				flattenValue(key, child, fields)
+			} else {
+				flattenValue(path+"."+key, child, fields)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenValue(fmt.Sprintf("%s[%d]", path, i), child, fields)
+		}
+	case nil:
+	default:
+		fields[path] = v
+	}
+}
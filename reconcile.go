@@ -0,0 +1,672 @@
+package haproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"sigs.k8s.io/yaml"
+)
+
+// the kinds of resource handled by the desired state
+const (
+	ResourceFrontend             = "frontend"
+	ResourceBackend              = "backend"
+	ResourceServer               = "server"
+	ResourceBind                 = "bind"
+	ResourceAcl                  = "acl"
+	ResourceHttpRequestRule      = "http_request_rule"
+	ResourceHttpResponseRule     = "http_response_rule"
+	ResourceTcpRequestRule       = "tcp_request_rule"
+	ResourceTcpResponseRule      = "tcp_response_rule"
+	ResourceBackendSwitchingRule = "backend_switching_rule"
+	ResourceServerSwitchingRule  = "server_switching_rule"
+)
+
+// the frontends and backends (with their children) a node must run.
+//
+// Reconcile owns the whole configuration: the frontends and backends not listed are deleted,
+// and the children lists are applied in the given order, their Index fields are ignored.
+// Every object also carries the dataplane fields its model has no field for. For the frontends, backends,
+// servers and binds the live value of such a field is kept when the document does not set it, the acls
+// and rules are compared and replaced whole.
+type HaproxyDesiredState struct {
+	Frontends []HaproxyDesiredFrontend `json:"frontends,omitempty"`
+	Backends  []HaproxyDesiredBackend  `json:"backends,omitempty"`
+}
+
+type HaproxyDesiredFrontend struct {
+	Frontend              HaproxyAddFrontend               `json:"frontend"`
+	Binds                 []HaproxyAddBind                 `json:"binds,omitempty"`
+	Acls                  []HaproxyAddAcl                  `json:"acls,omitempty"`
+	HttpRequestRules      []HaproxyAddHttpRequestRule      `json:"http_request_rules,omitempty"`
+	HttpResponseRules     []HaproxyAddHttpResponseRule     `json:"http_response_rules,omitempty"`
+	TcpRequestRules       []HaproxyAddTcpRequestRule       `json:"tcp_request_rules,omitempty"`
+	BackendSwitchingRules []HaproxyAddBackendSwitchingRule `json:"backend_switching_rules,omitempty"`
+}
+
+type HaproxyDesiredBackend struct {
+	Backend              HaproxyAddBackend               `json:"backend"`
+	Servers              []HaproxyAddServer              `json:"servers,omitempty"`
+	Acls                 []HaproxyAddAcl                 `json:"acls,omitempty"`
+	HttpRequestRules     []HaproxyAddHttpRequestRule     `json:"http_request_rules,omitempty"`
+	HttpResponseRules    []HaproxyAddHttpResponseRule    `json:"http_response_rules,omitempty"`
+	TcpRequestRules      []HaproxyAddTcpRequestRule      `json:"tcp_request_rules,omitempty"`
+	TcpResponseRules     []HaproxyAddTcpResponseRule     `json:"tcp_response_rules,omitempty"`
+	ServerSwitchingRules []HaproxyAddServerSwitchingRule `json:"server_switching_rules,omitempty"`
+}
+
+// parse a desired state document, YAML or JSON, unknown fields are rejected but in the dataplane
+// objects (frontends, backends, servers, binds, acls and rules) where they are passed to the dataplane api as they are
+func ParseDesiredState(data []byte) (*HaproxyDesiredState, error) {
+	state := HaproxyDesiredState{}
+	if err := yaml.UnmarshalStrict(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+type HaproxyChangeAction string
+
+const (
+	ChangeCreate HaproxyChangeAction = "create"
+	ChangeUpdate HaproxyChangeAction = "update"
+	ChangeDelete HaproxyChangeAction = "delete"
+)
+
+// a single write needed to reach the desired state, children are identified by their parent
+// and either their Name (servers, acls) or their Index (rules)
+type HaproxyChange struct {
+	Action     HaproxyChangeAction `json:"action"`
+	Kind       string              `json:"kind"`
+	ParentType string              `json:"parent_type,omitempty"`
+	ParentName string              `json:"parent_name,omitempty"`
+	Name       string              `json:"name,omitempty"`
+	Index      *int                `json:"index,omitempty"`
+	Fields     []HaproxyFieldDiff  `json:"fields,omitempty"`
+
+	apply func(client IHaproxyClient, transactionId string) error
+}
+
+func (c HaproxyChange) String() string {
+	target := c.Kind
+	if c.ParentName != "" {
+		target += " " + c.ParentType + "/" + c.ParentName
+	}
+	if c.Index != nil {
+		target += fmt.Sprintf("[%d]", *c.Index)
+	}
+	if c.Name != "" {
+		target += " " + c.Name
+	}
+	return string(c.Action) + " " + target
+}
+
+// compute the changes against the live configuration and apply them in a single transaction,
+// returns the applied changes. Nothing is written when the configuration is already up to date.
+func (h *haproxyClient) Reconcile(desired *HaproxyDesiredState) ([]HaproxyChange, error) {
+	plan, err := h.Plan(desired)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.ApplyPlan(plan); err != nil {
+		return nil, err
+	}
+	return plan.Changes, nil
+}
+
+// export the live frontends (with their binds), backends, servers, acls and rules as a desired state,
+// reconciling the exported state right away is a no-op.
+//
+// The objects are exported whole, with the fields their model has no field for.
+func (h *haproxyClient) Export() (*HaproxyDesiredState, error) {
+	return h.fetchState()
+}
+
+// the YAML document accepted by ParseDesiredState, use json.Marshal for the JSON one
+func (s *HaproxyDesiredState) ToYAML() ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.JSONToYAML(data)
+}
+
+// read the live configuration in the desired state shape
+func (h *haproxyClient) fetchState() (*HaproxyDesiredState, error) {
+	state := HaproxyDesiredState{}
+	frontends, err := h.GetFrontends()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range frontends.Data {
+		frontend := HaproxyDesiredFrontend{}
+		whole, err := h.GetFrontend(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		frontend.Frontend = whole.Data
+		binds, err := h.GetBinds(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		frontend.Binds = binds.Data
+		acls, err := h.GetAcls(ResourceFrontend, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		frontend.Acls = acls.Data
+		httpRequestRules, err := h.GetHttpRequestRules(ResourceFrontend, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		frontend.HttpRequestRules = httpRequestRules.Data
+		httpResponseRules, err := h.GetHttpResponseRules(ResourceFrontend, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		frontend.HttpResponseRules = httpResponseRules.Data
+		tcpRequestRules, err := h.GetTcpRequestRules(ResourceFrontend, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		frontend.TcpRequestRules = tcpRequestRules.Data
+		switchingRules, err := h.GetBackendSwitchingRules(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		frontend.BackendSwitchingRules = switchingRules.Data
+		state.Frontends = append(state.Frontends, frontend)
+	}
+
+	backends, err := h.GetBackends()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range backends.Data {
+		backend := HaproxyDesiredBackend{}
+		whole, err := h.GetBackend(b.Name)
+		if err != nil {
+			return nil, err
+		}
+		backend.Backend = whole.Data
+		servers, err := h.GetServers(b.Name)
+		if err != nil {
+			return nil, err
+		}
+		backend.Servers = servers.Data
+		acls, err := h.GetAcls(ResourceBackend, b.Name)
+		if err != nil {
+			return nil, err
+		}
+		backend.Acls = acls.Data
+		httpRequestRules, err := h.GetHttpRequestRules(ResourceBackend, b.Name)
+		if err != nil {
+			return nil, err
+		}
+		backend.HttpRequestRules = httpRequestRules.Data
+		httpResponseRules, err := h.GetHttpResponseRules(ResourceBackend, b.Name)
+		if err != nil {
+			return nil, err
+		}
+		backend.HttpResponseRules = httpResponseRules.Data
+		tcpRequestRules, err := h.GetTcpRequestRules(ResourceBackend, b.Name)
+		if err != nil {
+			return nil, err
+		}
+		backend.TcpRequestRules = tcpRequestRules.Data
+		tcpResponseRules, err := h.GetTcpResponseRules(b.Name)
+		if err != nil {
+			return nil, err
+		}
+		backend.TcpResponseRules = tcpResponseRules.Data
+		switchingRules, err := h.GetServerSwitchingRules(b.Name)
+		if err != nil {
+			return nil, err
+		}
+		backend.ServerSwitchingRules = switchingRules.Data
+		state.Backends = append(state.Backends, backend)
+	}
+	return &state, nil
+}
+
+// compute the ordered changes turning live into desired: backends are written before the frontends
+// routing to them and deleted after them
+func diffStates(live *HaproxyDesiredState, desired *HaproxyDesiredState) ([]HaproxyChange, error) {
+	if err := desired.Validate(); err != nil {
+		return nil, err
+	}
+	changes := []HaproxyChange{}
+
+	liveBackends := map[string]*HaproxyDesiredBackend{}
+	for i := range live.Backends {
+		liveBackends[live.Backends[i].Backend.Name] = &live.Backends[i]
+	}
+	for i := range desired.Backends {
+		want := desired.Backends[i]
+		have, exist := liveBackends[want.Backend.Name]
+		if exist {
+			// the live fields the document does not set are kept, and the options of the balance
+			// algorithm and forwardfor while those are unchanged
+			want.Backend.keep(&have.Backend.unmodeledFields)
+			if want.Backend.Balance.Algorithm == have.Backend.Balance.Algorithm {
+				want.Backend.Balance.keep(&have.Backend.Balance.unmodeledFields)
+			}
+			if want.Backend.Forwardfor.Enabled == have.Backend.Forwardfor.Enabled {
+				want.Backend.Forwardfor.keep(&have.Backend.Forwardfor.unmodeledFields)
+			}
+		}
+		if !exist {
+			have = &HaproxyDesiredBackend{}
+			changes = append(changes, namedChange(ChangeCreate, ResourceBackend, "", "", want.Backend.Name, nil, want.Backend,
+				func(client IHaproxyClient, transactionId string) error {
+					return client.AddBackend(transactionId, &want.Backend)
+				}))
+		} else if !reflect.DeepEqual(have.Backend, want.Backend) {
+			changes = append(changes, namedChange(ChangeUpdate, ResourceBackend, "", "", want.Backend.Name, have.Backend, want.Backend,
+				func(client IHaproxyClient, transactionId string) error {
+					return client.ReplaceBackend(want.Backend.Name, transactionId, &want.Backend)
+				}))
+		}
+		changes = append(changes, backendChildrenChanges(have, &want)...)
+	}
+
+	liveFrontends := map[string]*HaproxyDesiredFrontend{}
+	for i := range live.Frontends {
+		liveFrontends[live.Frontends[i].Frontend.Name] = &live.Frontends[i]
+	}
+	for i := range desired.Frontends {
+		want := desired.Frontends[i]
+		have, exist := liveFrontends[want.Frontend.Name]
+		if exist {
+			// the live fields the document does not set are kept
+			want.Frontend.keep(&have.Frontend.unmodeledFields)
+		}
+		if !exist {
+			have = &HaproxyDesiredFrontend{}
+			changes = append(changes, namedChange(ChangeCreate, ResourceFrontend, "", "", want.Frontend.Name, nil, want.Frontend,
+				func(client IHaproxyClient, transactionId string) error {
+					return client.AddFrontend(transactionId, &want.Frontend)
+				}))
+		} else if !reflect.DeepEqual(have.Frontend, want.Frontend) {
+			changes = append(changes, namedChange(ChangeUpdate, ResourceFrontend, "", "", want.Frontend.Name, have.Frontend, want.Frontend,
+				func(client IHaproxyClient, transactionId string) error {
+					return client.ReplaceFrontend(want.Frontend.Name, transactionId, &want.Frontend)
+				}))
+		}
+		changes = append(changes, frontendChildrenChanges(have, &want)...)
+	}
+
+	desiredFrontends := map[string]bool{}
+	for _, f := range desired.Frontends {
+		desiredFrontends[f.Frontend.Name] = true
+	}
+	for _, f := range live.Frontends {
+		name := f.Frontend.Name
+		if !desiredFrontends[name] {
+			changes = append(changes, namedChange(ChangeDelete, ResourceFrontend, "", "", name, f.Frontend, nil,
+				func(client IHaproxyClient, transactionId string) error {
+					return client.DeleteFrontend(name, transactionId)
+				}))
+		}
+	}
+	desiredBackends := map[string]bool{}
+	for _, b := range desired.Backends {
+		desiredBackends[b.Backend.Name] = true
+	}
+	for _, b := range live.Backends {
+		name := b.Backend.Name
+		if !desiredBackends[name] {
+			changes = append(changes, namedChange(ChangeDelete, ResourceBackend, "", "", name, b.Backend, nil,
+				func(client IHaproxyClient, transactionId string) error {
+					return client.DeleteBackend(name, transactionId)
+				}))
+		}
+	}
+	return changes, nil
+}
+
+func frontendChildrenChanges(have *HaproxyDesiredFrontend, want *HaproxyDesiredFrontend) []HaproxyChange {
+	parent := want.Frontend.Name
+	changes := namedChildrenChanges(ResourceBind, ResourceFrontend, parent, have.Binds, want.Binds,
+		func(bind HaproxyAddBind) string { return bind.Name },
+		func(client IHaproxyClient, transactionId string, bind *HaproxyAddBind) error {
+			return client.AddBind(parent, transactionId, bind)
+		},
+		func(client IHaproxyClient, transactionId string, name string, bind *HaproxyAddBind) error {
+			return client.ReplaceBind(parent, name, transactionId, bind)
+		},
+		func(client IHaproxyClient, transactionId string, name string) error {
+			return client.DeleteBind(parent, name, transactionId)
+		})
+	changes = append(changes, ruleChanges(ResourceAcl, ResourceFrontend, parent, have.Acls, want.Acls,
+		func(client IHaproxyClient, transactionId string, rule *HaproxyAddAcl) error {
+			return client.AddAcl(ResourceFrontend, parent, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int, rule *HaproxyAddAcl) error {
+			return client.ReplaceAcl(ResourceFrontend, parent, index, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int) error {
+			return client.DeleteAcl(ResourceFrontend, parent, index, transactionId)
+		})...)
+	changes = append(changes, ruleChanges(ResourceHttpRequestRule, ResourceFrontend, parent, have.HttpRequestRules, want.HttpRequestRules,
+		func(client IHaproxyClient, transactionId string, rule *HaproxyAddHttpRequestRule) error {
+			return client.AddHttpRequestRule(ResourceFrontend, parent, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int, rule *HaproxyAddHttpRequestRule) error {
+			return client.ReplaceHttpRequestRule(ResourceFrontend, parent, index, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int) error {
+			return client.DeleteHttpRequestRule(ResourceFrontend, parent, index, transactionId)
+		})...)
+	changes = append(changes, ruleChanges(ResourceHttpResponseRule, ResourceFrontend, parent, have.HttpResponseRules, want.HttpResponseRules,
+		func(client IHaproxyClient, transactionId string, rule *HaproxyAddHttpResponseRule) error {
+			return client.AddHttpResponseRule(ResourceFrontend, parent, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int, rule *HaproxyAddHttpResponseRule) error {
+			return client.ReplaceHttpResponseRule(ResourceFrontend, parent, index, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int) error {
+			return client.DeleteHttpResponseRule(ResourceFrontend, parent, index, transactionId)
+		})...)
+	changes = append(changes, ruleChanges(ResourceTcpRequestRule, ResourceFrontend, parent, have.TcpRequestRules, want.TcpRequestRules,
+		func(client IHaproxyClient, transactionId string, rule *HaproxyAddTcpRequestRule) error {
+			return client.AddTcpRequestRule(ResourceFrontend, parent, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int, rule *HaproxyAddTcpRequestRule) error {
+			return client.ReplaceTcpRequestRule(ResourceFrontend, parent, index, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int) error {
+			return client.DeleteTcpRequestRule(ResourceFrontend, parent, index, transactionId)
+		})...)
+	changes = append(changes, ruleChanges(ResourceBackendSwitchingRule, ResourceFrontend, parent, have.BackendSwitchingRules, want.BackendSwitchingRules,
+		func(client IHaproxyClient, transactionId string, rule *HaproxyAddBackendSwitchingRule) error {
+			return client.AddBackendSwitchingRule(parent, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int, rule *HaproxyAddBackendSwitchingRule) error {
+			return client.ReplaceBackendSwitchingRule(parent, index, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int) error {
+			return client.DeleteBackendSwitchingRule(parent, index, transactionId)
+		})...)
+	return changes
+}
+
+func backendChildrenChanges(have *HaproxyDesiredBackend, want *HaproxyDesiredBackend) []HaproxyChange {
+	parent := want.Backend.Name
+	changes := namedChildrenChanges(ResourceServer, ResourceBackend, parent, have.Servers, want.Servers,
+		func(server HaproxyAddServer) string { return server.Name },
+		func(client IHaproxyClient, transactionId string, server *HaproxyAddServer) error {
+			return client.AddServer(parent, transactionId, server)
+		},
+		func(client IHaproxyClient, transactionId string, name string, server *HaproxyAddServer) error {
+			return client.ReplaceServer(parent, name, transactionId, server)
+		},
+		func(client IHaproxyClient, transactionId string, name string) error {
+			return client.DeleteServer(parent, name, transactionId)
+		})
+
+	changes = append(changes, ruleChanges(ResourceAcl, ResourceBackend, parent, have.Acls, want.Acls,
+		func(client IHaproxyClient, transactionId string, rule *HaproxyAddAcl) error {
+			return client.AddAcl(ResourceBackend, parent, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int, rule *HaproxyAddAcl) error {
+			return client.ReplaceAcl(ResourceBackend, parent, index, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int) error {
+			return client.DeleteAcl(ResourceBackend, parent, index, transactionId)
+		})...)
+	changes = append(changes, ruleChanges(ResourceHttpRequestRule, ResourceBackend, parent, have.HttpRequestRules, want.HttpRequestRules,
+		func(client IHaproxyClient, transactionId string, rule *HaproxyAddHttpRequestRule) error {
+			return client.AddHttpRequestRule(ResourceBackend, parent, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int, rule *HaproxyAddHttpRequestRule) error {
+			return client.ReplaceHttpRequestRule(ResourceBackend, parent, index, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int) error {
+			return client.DeleteHttpRequestRule(ResourceBackend, parent, index, transactionId)
+		})...)
+	changes = append(changes, ruleChanges(ResourceHttpResponseRule, ResourceBackend, parent, have.HttpResponseRules, want.HttpResponseRules,
+		func(client IHaproxyClient, transactionId string, rule *HaproxyAddHttpResponseRule) error {
+			return client.AddHttpResponseRule(ResourceBackend, parent, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int, rule *HaproxyAddHttpResponseRule) error {
+			return client.ReplaceHttpResponseRule(ResourceBackend, parent, index, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int) error {
+			return client.DeleteHttpResponseRule(ResourceBackend, parent, index, transactionId)
+		})...)
+	changes = append(changes, ruleChanges(ResourceTcpRequestRule, ResourceBackend, parent, have.TcpRequestRules, want.TcpRequestRules,
+		func(client IHaproxyClient, transactionId string, rule *HaproxyAddTcpRequestRule) error {
+			return client.AddTcpRequestRule(ResourceBackend, parent, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int, rule *HaproxyAddTcpRequestRule) error {
+			return client.ReplaceTcpRequestRule(ResourceBackend, parent, index, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int) error {
+			return client.DeleteTcpRequestRule(ResourceBackend, parent, index, transactionId)
+		})...)
+	changes = append(changes, ruleChanges(ResourceTcpResponseRule, ResourceBackend, parent, have.TcpResponseRules, want.TcpResponseRules,
+		func(client IHaproxyClient, transactionId string, rule *HaproxyAddTcpResponseRule) error {
+			return client.AddTcpResponseRule(parent, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int, rule *HaproxyAddTcpResponseRule) error {
+			return client.ReplaceTcpResponseRule(parent, index, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int) error {
+			return client.DeleteTcpResponseRule(parent, index, transactionId)
+		})...)
+	changes = append(changes, ruleChanges(ResourceServerSwitchingRule, ResourceBackend, parent, have.ServerSwitchingRules, want.ServerSwitchingRules,
+		func(client IHaproxyClient, transactionId string, rule *HaproxyAddServerSwitchingRule) error {
+			return client.AddServerSwitchingRule(parent, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int, rule *HaproxyAddServerSwitchingRule) error {
+			return client.ReplaceServerSwitchingRule(parent, index, transactionId, rule)
+		},
+		func(client IHaproxyClient, transactionId string, index int) error {
+			return client.DeleteServerSwitchingRule(parent, index, transactionId)
+		})...)
+	return changes
+}
+
+// changes of a children list identified by name (servers, binds), their order is not relevant
+func namedChildrenChanges[T any](kind string, parentType string, parentName string, current []T, desired []T, name func(T) string,
+	insert func(client IHaproxyClient, transactionId string, child *T) error,
+	replace func(client IHaproxyClient, transactionId string, name string, child *T) error,
+	remove func(client IHaproxyClient, transactionId string, name string) error) []HaproxyChange {
+	changes := []HaproxyChange{}
+	live := map[string]T{}
+	for _, child := range current {
+		live[name(child)] = child
+	}
+	wanted := map[string]bool{}
+	for i := range desired {
+		child := desired[i]
+		childName := name(child)
+		wanted[childName] = true
+		have, exist := live[childName]
+		if model, ok := any(&child).(unmodeledModel); ok && exist {
+			// the live fields the document does not set are kept
+			model.unmodeled().keep(any(&have).(unmodeledModel).unmodeled())
+		}
+		if !exist {
+			changes = append(changes, namedChange(ChangeCreate, kind, parentType, parentName, childName, nil, child,
+				func(client IHaproxyClient, transactionId string) error {
+					return insert(client, transactionId, &child)
+				}))
+		} else if !reflect.DeepEqual(have, child) {
+			changes = append(changes, namedChange(ChangeUpdate, kind, parentType, parentName, childName, have, child,
+				func(client IHaproxyClient, transactionId string) error {
+					return replace(client, transactionId, childName, &child)
+				}))
+		}
+	}
+	for _, child := range current {
+		childName := name(child)
+		if !wanted[childName] {
+			changes = append(changes, namedChange(ChangeDelete, kind, parentType, parentName, childName, child, nil,
+				func(client IHaproxyClient, transactionId string) error {
+					return remove(client, transactionId, childName)
+				}))
+		}
+	}
+	return changes
+}
+
+func namedChange(action HaproxyChangeAction, kind string, parentType string, parentName string, name string, current interface{}, desired interface{}, apply func(client IHaproxyClient, transactionId string) error) HaproxyChange {
+	return HaproxyChange{Action: action, Kind: kind, ParentType: parentType, ParentName: parentName, Name: name, Fields: fieldDiffs(current, desired), apply: apply}
+}
+
+// turn the PlanRuleOrder operations of a children list into changes
+func ruleChanges[T any](kind string, parentType string, parentName string, current []T, desired []T,
+	insert func(client IHaproxyClient, transactionId string, rule *T) error,
+	replace func(client IHaproxyClient, transactionId string, index int, rule *T) error,
+	remove func(client IHaproxyClient, transactionId string, index int) error) []HaproxyChange {
+	changes := []HaproxyChange{}
+	for _, op := range PlanRuleOrder(current, desired) {
+		op := op
+		change := HaproxyChange{Kind: kind, ParentType: parentType, ParentName: parentName, Index: &op.Index}
+		if acl, ok := any(op.Rule).(*HaproxyAddAcl); ok && acl != nil {
+			change.Name = acl.AclName
+		} else if acl, ok := any(op.Current).(*HaproxyAddAcl); ok && acl != nil {
+			change.Name = acl.AclName
+		}
+		switch op.Type {
+		case RuleOperationInsert:
+			change.Action = ChangeCreate
+			change.Fields = fieldDiffs(nil, op.Rule)
+			change.apply = func(client IHaproxyClient, transactionId string) error {
+				return insert(client, transactionId, op.Rule)
+			}
+		case RuleOperationReplace:
+			change.Action = ChangeUpdate
+			change.Fields = fieldDiffs(op.Current, op.Rule)
+			change.apply = func(client IHaproxyClient, transactionId string) error {
+				return replace(client, transactionId, op.Index, op.Rule)
+			}
+		case RuleOperationDelete:
+			change.Action = ChangeDelete
+			change.Fields = fieldDiffs(op.Current, nil)
+			change.apply = func(client IHaproxyClient, transactionId string) error {
+				return remove(client, transactionId, op.Index)
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// validate the names and every model of the state, see the Validate methods of the models
+func (s *HaproxyDesiredState) Validate() error {
+	if err := s.validateNames(); err != nil {
+		return err
+	}
+	for _, f := range s.Frontends {
+		models := []interface{ Validate() error }{&f.Frontend}
+		for i := range f.Binds {
+			models = append(models, &f.Binds[i])
+		}
+		for i := range f.Acls {
+			models = append(models, &f.Acls[i])
+		}
+		for i := range f.HttpRequestRules {
+			models = append(models, &f.HttpRequestRules[i])
+		}
+		for i := range f.HttpResponseRules {
+			models = append(models, &f.HttpResponseRules[i])
+		}
+		for i := range f.TcpRequestRules {
+			models = append(models, &f.TcpRequestRules[i])
+		}
+		for i := range f.BackendSwitchingRules {
+			models = append(models, &f.BackendSwitchingRules[i])
+		}
+		if err := validateModels(models); err != nil {
+			return fmt.Errorf("frontend %s: %w", f.Frontend.Name, err)
+		}
+	}
+	for _, b := range s.Backends {
+		models := []interface{ Validate() error }{&b.Backend}
+		for i := range b.Servers {
+			models = append(models, &b.Servers[i])
+		}
+		for i := range b.Acls {
+			models = append(models, &b.Acls[i])
+		}
+		for i := range b.HttpRequestRules {
+			models = append(models, &b.HttpRequestRules[i])
+		}
+		for i := range b.HttpResponseRules {
+			models = append(models, &b.HttpResponseRules[i])
+		}
+		for i := range b.TcpRequestRules {
+			models = append(models, &b.TcpRequestRules[i])
+		}
+		for i := range b.TcpResponseRules {
+			models = append(models, &b.TcpResponseRules[i])
+		}
+		for i := range b.ServerSwitchingRules {
+			models = append(models, &b.ServerSwitchingRules[i])
+		}
+		if err := validateModels(models); err != nil {
+			return fmt.Errorf("backend %s: %w", b.Backend.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateModels(models []interface{ Validate() error }) error {
+	for _, model := range models {
+		if err := model.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *HaproxyDesiredState) validateNames() error {
+	frontends := map[string]bool{}
+	for _, f := range s.Frontends {
+		if f.Frontend.Name == "" {
+			return errors.New("desired frontend without name")
+		}
+		if frontends[f.Frontend.Name] {
+			return fmt.Errorf("frontend %s is defined more than once", f.Frontend.Name)
+		}
+		frontends[f.Frontend.Name] = true
+	}
+	backends := map[string]bool{}
+	for _, b := range s.Backends {
+		if b.Backend.Name == "" {
+			return errors.New("desired backend without name")
+		}
+		if backends[b.Backend.Name] {
+			return fmt.Errorf("backend %s is defined more than once", b.Backend.Name)
+		}
+		backends[b.Backend.Name] = true
+		servers := map[string]bool{}
+		for _, server := range b.Servers {
+			if server.Name == "" {
+				return fmt.Errorf("server without name in backend %s", b.Backend.Name)
+			}
+			if servers[server.Name] {
+				return fmt.Errorf("server %s is defined more than once in backend %s", server.Name, b.Backend.Name)
+			}
+			servers[server.Name] = true
+		}
+	}
+	for _, f := range s.Frontends {
+		binds := map[string]bool{}
+		for _, bind := range f.Binds {
+			if bind.Name == "" {
+				return fmt.Errorf("bind without name in frontend %s", f.Frontend.Name)
+			}
+			if binds[bind.Name] {
+				return fmt.Errorf("bind %s is defined more than once in frontend %s", bind.Name, f.Frontend.Name)
+			}
+			binds[bind.Name] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,431 @@
+package haproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func parseState(t *testing.T, document string) *HaproxyDesiredState {
+	t.Helper()
+	state, err := ParseDesiredState([]byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+const liveState = `
+backends:
+  - backend: {name: app, mode: http, balance: {algorithm: roundrobin}, http-reuse: safe}
+    servers:
+      - {name: app1, address: 10.0.0.1, port: 8080, check: enabled}
+      - {name: app2, address: 10.0.0.2, port: 8080, check: enabled}
+  - backend: {name: old, mode: http, balance: {algorithm: roundrobin}}
+frontends:
+  - frontend: {name: www, mode: http, default_backend: app}
+    acls:
+      - {acl_name: is_api, criterion: path_beg, value: /api}
+`
+
+func TestDiffStates(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired string
+		changes []string
+	}{
+		{
+			"unchanged, the unmodeled live fields are kept",
+			`
+backends:
+  - backend: {name: app, mode: http, balance: {algorithm: roundrobin}}
+    servers:
+      - {name: app1, address: 10.0.0.1, port: 8080, check: enabled}
+      - {name: app2, address: 10.0.0.2, port: 8080, check: enabled}
+  - backend: {name: old, mode: http, balance: {algorithm: roundrobin}}
+frontends:
+  - frontend: {name: www, mode: http, default_backend: app}
+    acls:
+      - {acl_name: is_api, criterion: path_beg, value: /api}
+`,
+			[]string{},
+		},
+		{
+			"create, update and delete",
+			`
+backends:
+  - backend: {name: app, mode: http, balance: {algorithm: leastconn}}
+    servers:
+      - {name: app1, address: 10.0.0.1, port: 8080, check: enabled}
+      - {name: app3, address: 10.0.0.3, port: 8080, check: enabled}
+  - backend: {name: api, mode: http, balance: {algorithm: roundrobin}}
+frontends:
+  - frontend: {name: www, mode: http, default_backend: app}
+    acls:
+      - {acl_name: is_api, criterion: path_beg, value: /api}
+    backend_switching_rules:
+      - {name: api, cond: if, cond_test: is_api}
+`,
+			[]string{
+				"update backend app",
+				"create server backend/app app3",
+				"delete server backend/app app2",
+				"create backend api",
+				"create backend_switching_rule frontend/www[0]",
+				"delete backend old",
+			},
+		},
+		{
+			"rules are diffed by position",
+			`
+backends:
+  - backend: {name: app, mode: http, balance: {algorithm: roundrobin}}
+    servers:
+      - {name: app1, address: 10.0.0.1, port: 8080, check: enabled}
+      - {name: app2, address: 10.0.0.2, port: 8080, check: enabled}
+  - backend: {name: old, mode: http, balance: {algorithm: roundrobin}}
+frontends:
+  - frontend: {name: www, mode: http, default_backend: app}
+    acls:
+      - {acl_name: is_static, criterion: path_beg, value: /static}
+      - {acl_name: is_api, criterion: path_beg, value: /api}
+`,
+			[]string{"create acl frontend/www[0] is_static"},
+		},
+		{
+			"missing frontends are deleted",
+			`
+backends:
+  - backend: {name: app, mode: http, balance: {algorithm: roundrobin}}
+    servers:
+      - {name: app1, address: 10.0.0.1, port: 8080, check: enabled}
+      - {name: app2, address: 10.0.0.2, port: 8080, check: enabled}
+  - backend: {name: old, mode: http, balance: {algorithm: roundrobin}}
+`,
+			[]string{"delete frontend www"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes, err := diffStates(parseState(t, liveState), parseState(t, test.desired))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, change := range changes {
+				got = append(got, change.String())
+			}
+			if !reflect.DeepEqual(got, test.changes) {
+				t.Fatalf("changes %q, want %q", got, test.changes)
+			}
+		})
+	}
+}
+
+func TestDiffStatesInvalid(t *testing.T) {
+	desired := &HaproxyDesiredState{Backends: []HaproxyDesiredBackend{{Backend: HaproxyAddBackend{Name: "app", Mode: "udp"}}}}
+	if _, err := diffStates(&HaproxyDesiredState{}, desired); err == nil {
+		t.Fatal("an invalid desired state was diffed")
+	}
+}
+
+func TestPlanString(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired string
+		want    string
+	}{
+		{"no changes", liveState, "No changes, the configuration is up to date.\n"},
+		{
+			"changes",
+			`
+backends:
+  - backend: {name: app, mode: http, balance: {algorithm: leastconn}}
+    servers:
+      - {name: app1, address: 10.0.0.1, port: 8080, check: enabled}
+      - {name: app2, address: 10.0.0.2, port: 8080, check: enabled}
+frontends:
+  - frontend: {name: www, mode: http, default_backend: app}
+    acls:
+      - {acl_name: is_api, criterion: path_beg, value: /api}
+      - {acl_name: is_static, criterion: path_beg, value: /static}
+`,
+			`~ update backend app
+    balance.algorithm: "roundrobin" -> "leastconn"
++ create acl frontend/www[1] is_static
+    acl_name: "is_static"
+    criterion: "path_beg"
+    value: "/static"
+- delete backend old
+
+Plan: 1 to create, 1 to update, 1 to delete.
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes, err := diffStates(parseState(t, liveState), parseState(t, test.desired))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plan := HaproxyPlan{Changes: changes}
+			if got := plan.String(); got != test.want {
+				t.Fatalf("plan\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestModelRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		model interface{}
+		data  string
+	}{
+		{
+			"http-request redirect scheme https",
+			&HaproxyAddHttpRequestRule{},
+			`{"cond":"if","cond_test":"!{ ssl_fc }","index":0,"redir_code":301,"redir_type":"scheme","redir_value":"https","type":"redirect"}`,
+		},
+		{
+			"http-response rule",
+			&HaproxyAddHttpResponseRule{},
+			`{"hdr_format":"max-age=31536000","hdr_name":"Strict-Transport-Security","index":2,"return_hdrs":[{"fmt":"x","name":"y"}],"type":"set-header"}`,
+		},
+		{
+			"acl",
+			&HaproxyAddAcl{},
+			`{"acl_name":"is_api","criterion":"path_beg","index":0,"value":"/api"}`,
+		},
+		{
+			"tcp-request rule",
+			&HaproxyAddTcpRequestRule{},
+			`{"action":"track-sc0","index":0,"track_key":"src","type":"connection"}`,
+		},
+		{
+			"tcp-response rule",
+			&HaproxyAddTcpResponseRule{},
+			`{"action":"accept","cond":"if","cond_test":"{ res.len gt 0 }","index":1,"type":"content"}`,
+		},
+		{
+			"backend switching rule",
+			&HaproxyAddBackendSwitchingRule{},
+			`{"cond":"if","cond_test":"is_api","index":0,"name":"api"}`,
+		},
+		{
+			"server switching rule",
+			&HaproxyAddServerSwitchingRule{},
+			`{"index":0,"target_server":"app1"}`,
+		},
+		{
+			"backend balance and forwardfor options",
+			&HaproxyAddBackend{},
+			`{"balance":{"algorithm":"hdr","hdr_name":"X-Tenant","hdr_use_domain_only":true},"forwardfor":{"enabled":"enabled","except":"127.0.0.0/8","header":"X-Real-IP"},"mode":"http","name":"app"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := json.Unmarshal([]byte(test.data), test.model); err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(test.model)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the dataplane does not care for the order of the fields
+			var got, want interface{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.data), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("written back as\n%s\nwant\n%s", data, test.data)
+			}
+		})
+	}
+}
+
+func TestDiffStatesUnmodeledFields(t *testing.T) {
+	live := `
+backends:
+  - backend: {name: app, balance: {algorithm: hdr, hdr_name: X-Tenant}, forwardfor: {enabled: enabled, except: 127.0.0.0/8}}
+frontends:
+  - frontend: {name: www, default_backend: app}
+    http_request_rules:
+      - {type: redirect, redir_type: scheme, redir_value: https, cond: unless, cond_test: "{ ssl_fc }"}
+`
+	tests := []struct {
+		name    string
+		desired string
+		changes []string
+	}{
+		{
+			"unchanged, the options of the same algorithm are kept",
+			`
+backends:
+  - backend: {name: app, balance: {algorithm: hdr}, forwardfor: {enabled: enabled}}
+frontends:
+  - frontend: {name: www, default_backend: app}
+    http_request_rules:
+      - {type: redirect, redir_type: scheme, redir_value: https, cond: unless, cond_test: "{ ssl_fc }"}
+`,
+			[]string{},
+		},
+		{
+			"another redirect is not the same rule",
+			`
+backends:
+  - backend: {name: app, balance: {algorithm: hdr}, forwardfor: {enabled: enabled}}
+frontends:
+  - frontend: {name: www, default_backend: app}
+    http_request_rules:
+      - {type: redirect, redir_type: location, redir_value: "https://www.example.com", cond: unless, cond_test: "{ ssl_fc }"}
+`,
+			[]string{"update http_request_rule frontend/www[0]"},
+		},
+		{
+			"the options of another algorithm are dropped",
+			`
+backends:
+  - backend: {name: app, balance: {algorithm: roundrobin}, forwardfor: {enabled: enabled, header: X-Client-IP}}
+frontends:
+  - frontend: {name: www, default_backend: app}
+    http_request_rules:
+      - {type: redirect, redir_type: scheme, redir_value: https, cond: unless, cond_test: "{ ssl_fc }"}
+`,
+			[]string{"update backend app"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes, err := diffStates(parseState(t, live), parseState(t, test.desired))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, change := range changes {
+				got = append(got, change.String())
+			}
+			if !reflect.DeepEqual(got, test.changes) {
+				t.Fatalf("changes %q, want %q", got, test.changes)
+			}
+		})
+	}
+}
+
+// a dataplane serving a configuration with fields the models have no field for, every other list is empty
+func exportDataplane(t *testing.T) *haproxyClient {
+	responses := map[string]string{
+		"/configuration/version":                                                  `1`,
+		"/configuration/frontends":                                                `{"_version":1,"data":[{"name":"www"}]}`,
+		"/configuration/frontends/www":                                            `{"_version":1,"data":{"name":"www","mode":"http","default_backend":"app","log_tag":"www","stats_options":{"stats_enable":true}}}`,
+		"/configuration/binds?frontend=www":                                       `{"_version":1,"data":[{"name":"https","address":"*","port":443,"ssl":true,"ssl_certificate":"/etc/ssl/www.pem","ssl_min_ver":"TLSv1.2"}]}`,
+		"/configuration/acls?parent_type=frontend&parent_name=www":                `{"_version":1,"data":[{"index":0,"acl_name":"is_api","criterion":"path_beg","value":"/api"}]}`,
+		"/configuration/http_request_rules?parent_type=frontend&parent_name=www":  `{"_version":1,"data":[{"index":0,"type":"redirect","redir_type":"scheme","redir_value":"https","redir_code":301,"cond":"unless","cond_test":"{ ssl_fc }"}]}`,
+		"/configuration/http_response_rules?parent_type=frontend&parent_name=www": `{"_version":1,"data":[{"index":0,"type":"set-header","hdr_name":"Strict-Transport-Security","hdr_format":"max-age=31536000"}]}`,
+		"/configuration/tcp_request_rules?parent_type=frontend&parent_name=www":   `{"_version":1,"data":[{"index":0,"type":"connection","action":"track-sc0","track_key":"src"}]}`,
+		"/configuration/backend_switching_rules?frontend=www":                     `{"_version":1,"data":[{"index":0,"name":"api","cond":"if","cond_test":"is_api"}]}`,
+		"/configuration/backends":                                                 `{"_version":1,"data":[{"name":"api"},{"name":"app"}]}`,
+		"/configuration/backends/api":                                             `{"_version":1,"data":{"name":"api","mode":"http","balance":{"algorithm":"uri","uri_depth":2},"adv_check":"httpchk","httpchk_params":{"method":"GET","uri":"/health"}}}`,
+		"/configuration/backends/app":                                             `{"_version":1,"data":{"name":"app","mode":"http","balance":{"algorithm":"hdr","hdr_name":"X-Tenant"},"forwardfor":{"enabled":"enabled","except":"127.0.0.0/8"}}}`,
+		"/configuration/servers?backend=api":                                      `{"_version":1,"data":[{"name":"api1","address":"10.0.0.1","port":8080,"check":"enabled","weight":10,"inter":2000,"rise":3}]}`,
+		"/configuration/servers?backend=app":                                      `{"_version":1,"data":[{"name":"app1","address":"10.0.0.2","port":8080,"ssl":"enabled","verify":"none"}]}`,
+		"/configuration/tcp_response_rules?backend=api":                           `{"_version":1,"data":[{"index":0,"type":"content","action":"accept","cond":"if","cond_test":"{ res.len gt 0 }"}]}`,
+		"/configuration/server_switching_rules?backend=app":                       `{"_version":1,"data":[{"index":0,"target_server":"app1","cond":"if","cond_test":"{ req.ssl_sni -m end .internal }"}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("unexpected %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/v2/services/haproxy")
+		if r.URL.RawQuery != "" {
+			key += "?" + r.URL.RawQuery
+		}
+		response, found := responses[key]
+		if !found {
+			response = `{"_version":1,"data":[]}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return &haproxyClient{Url: server.URL, Rest: resty.New()}
+}
+
+func TestExportReapplied(t *testing.T) {
+	client := exportDataplane(t)
+	exported, err := client.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	document, err := exported.ToYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desired, err := ParseDesiredState(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := client.Plan(desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.HasChanges() {
+		t.Fatalf("re-applying the export plans\n%s\nfrom the document\n%s", plan, document)
+	}
+}
+
+func TestApplyPlanDeleteError(t *testing.T) {
+	tests := []struct {
+		name         string
+		deleteStatus int
+		err          string
+	}{
+		{"transaction deleted", http.StatusNoContent, "create backend app: backend app already exists"},
+		{"transaction left", http.StatusNotFound, "create backend app: backend app already exists (the transaction tx1 is left: transaction not found)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.Method == http.MethodPost && r.URL.Path == "/v2/services/haproxy/transactions":
+					w.WriteHeader(http.StatusCreated)
+					w.Write([]byte(`{"id":"tx1","_version":1,"status":"in_progress"}`))
+				case r.Method == http.MethodPost:
+					w.WriteHeader(http.StatusConflict)
+					w.Write([]byte(`{"code":409,"message":"backend app already exists"}`))
+				case r.Method == http.MethodDelete && test.deleteStatus != http.StatusNoContent:
+					w.WriteHeader(test.deleteStatus)
+					w.Write([]byte(`{"code":404,"message":"transaction not found"}`))
+				default:
+					w.WriteHeader(test.deleteStatus)
+				}
+			}))
+			defer server.Close()
+			client := &haproxyClient{Url: server.URL, Rest: resty.New()}
+			changes, err := diffStates(&HaproxyDesiredState{}, parseState(t, "backends: [{backend: {name: app}}]"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = client.ApplyPlan(&HaproxyPlan{Version: 1, Changes: changes})
+			if err == nil || err.Error() != test.err {
+				t.Fatalf("error %v, want %s", err, test.err)
+			}
+			var dataplaneErr *HaproxyErrorResponse
+			if !errors.As(err, &dataplaneErr) || dataplaneErr.Code != http.StatusConflict {
+				t.Fatalf("error %v does not wrap the failed change", err)
+			}
+		})
+	}
+}
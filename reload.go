@@ -0,0 +1,44 @@
+package haproxy
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	ReloadInProgress = "in_progress"
+	ReloadFailed     = "failed"
+	ReloadSucceeded  = "succeeded"
+)
+
+const DefaultReloadInterval = time.Second
+
+// wait for the reload returned by CommitTransactionReload, polling every interval (DefaultReloadInterval
+// when not positive). An error is returned when the reload failed or the context is done first, an empty
+// reloadId (no reload) returns right away.
+func WaitForReload(ctx context.Context, client IHaproxyClient, reloadId string, interval time.Duration) error {
+	if reloadId == "" {
+		return nil
+	}
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	for {
+		reload, err := client.GetReload(reloadId)
+		if err != nil {
+			return err
+		}
+		switch reload.Status {
+		case ReloadSucceeded:
+			return nil
+		case ReloadFailed:
+			return fmt.Errorf("haproxy reload %s failed: %s", reload.ID, reload.Response)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package haproxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// a reload which stays in progress
+type fakeReloadNode struct {
+	IHaproxyClient
+	polls int
+}
+
+func (n *fakeReloadNode) GetReload(id string) (*HaproxyReload, error) {
+	n.polls++
+	return &HaproxyReload{ID: id, Status: ReloadInProgress}, nil
+}
+
+func TestWaitForReloadInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		polls    int
+	}{
+		{"zero interval waits the default one", 0, 1},
+		{"negative interval waits the default one", -time.Second, 1},
+		{"short interval", 20 * time.Millisecond, 5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := &fakeReloadNode{}
+			ctx, cancel := context.WithTimeout(context.Background(), 90*time.Millisecond)
+			defer cancel()
+			err := WaitForReload(ctx, node, "1", test.interval)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("error %v, want the context deadline", err)
+			}
+			if node.polls > test.polls {
+				t.Fatalf("%d polls, want at most %d", node.polls, test.polls)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package haproxy
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the leader polling interval used when none is given
+const DefaultReplicationInterval = 10 * time.Second
+
+// the outcome of a follower synchronization
+type HaproxySyncEvent struct {
+	Node            string
+	LeaderVersion   int
+	FollowerVersion int  // the follower version before the push
+	Pushed          bool // false when the follower was already in sync
+	Err             error
+	Time            time.Time
+}
+
+// replicates the configuration of a leader node to its followers whenever the leader version changes
+type HaproxyReplicator struct {
+	Leader    IHaproxyClient
+	Followers map[string]IHaproxyClient
+	Interval  time.Duration // how often the leader version is polled, DefaultReplicationInterval when not positive
+	// a failing follower is retried after MinBackoff, doubled on each failure up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	events chan HaproxySyncEvent
+	// guards closed and followers, held for a whole synchronization
+	lock      sync.Mutex
+	closed    bool
+	followers map[string]*followerState
+}
+
+type followerState struct {
+	syncedVersion int // the leader version the follower was last synced to, 0 when never synced
+	failures      int
+	retryAt       time.Time
+}
+
+// create a replicator polling the leader every interval, followers are keyed by a name used in the events
+func NewHaproxyReplicator(leader IHaproxyClient, followers map[string]IHaproxyClient, interval time.Duration) *HaproxyReplicator {
+	if interval <= 0 {
+		interval = DefaultReplicationInterval
+	}
+	return &HaproxyReplicator{
+		Leader:     leader,
+		Followers:  followers,
+		Interval:   interval,
+		MinBackoff: interval,
+		MaxBackoff: 10 * interval,
+		events:     make(chan HaproxySyncEvent, 64),
+		followers:  map[string]*followerState{},
+	}
+}
+
+// the results of every synchronization, events are dropped when the buffer is full.
+// The channel is closed when Run returns.
+func (r *HaproxyReplicator) Events() <-chan HaproxySyncEvent {
+	return r.events
+}
+
+// synchronize the followers until the context is done
+func (r *HaproxyReplicator) Run(ctx context.Context) error {
+	defer func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.closed = true
+		close(r.events)
+	}()
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultReplicationInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.SyncOnce()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// synchronize the followers behind the leader version, the ones in backoff are skipped.
+// Safe to call while Run is running, the synchronizations are serialized.
+func (r *HaproxyReplicator) SyncOnce() []HaproxySyncEvent {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	events := []HaproxySyncEvent{}
+	leaderVersion, err := r.Leader.GetConfigurationVersion()
+	if err != nil {
+		return append(events, r.emit(HaproxySyncEvent{Err: err}))
+	}
+
+	now := time.Now()
+	pending := []string{}
+	for _, node := range sortedKeys(r.Followers) {
+		state, exist := r.followers[node]
+		if !exist {
+			state = &followerState{}
+			r.followers[node] = state
+		}
+		if state.syncedVersion != *leaderVersion && !now.Before(state.retryAt) {
+			pending = append(pending, node)
+		}
+	}
+	if len(pending) == 0 {
+		return events
+	}
+
+	leader, err := r.Leader.GetRawConfiguration()
+	if err != nil {
+		return append(events, r.emit(HaproxySyncEvent{LeaderVersion: *leaderVersion, Err: err}))
+	}
+	for _, node := range pending {
+		event := r.syncFollower(node, leader)
+		state := r.followers[node]
+		if event.Err != nil {
+			state.failures++
+			state.retryAt = time.Now().Add(r.backoff(state.failures))
+		} else {
+			state.failures = 0
+			state.syncedVersion = leader.Version
+		}
+		events = append(events, r.emit(event))
+	}
+	return events
+}
+
+func (r *HaproxyReplicator) syncFollower(node string, leader *HaproxyRawConfiguration) HaproxySyncEvent {
+	event := HaproxySyncEvent{Node: node, LeaderVersion: leader.Version}
+	follower, err := r.Followers[node].GetRawConfiguration()
+	if err != nil {
+		event.Err = err
+		return event
+	}
+	event.FollowerVersion = follower.Version
+	if stripVersionLine(follower.Data) == stripVersionLine(leader.Data) {
+		return event
+	}
+	// pushing on the follower version fails if it was modified in the meantime
+	event.Err = r.Followers[node].PushRawConfiguration(follower.Version, stripVersionLine(leader.Data))
+	event.Pushed = event.Err == nil
+	return event
+}
+
+func (r *HaproxyReplicator) backoff(failures int) time.Duration {
+	backoff := r.MinBackoff
+	for i := 1; i < failures && backoff < r.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.MaxBackoff {
+		backoff = r.MaxBackoff
+	}
+	return backoff
+}
+
+// the caller holds the lock
+func (r *HaproxyReplicator) emit(event HaproxySyncEvent) HaproxySyncEvent {
+	event.Time = time.Now()
+	if r.closed {
+		return event
+	}
+	select {
+	case r.events <- event:
+	default:
+	}
+	return event
+}
+
+// the dataplane api prepends "# _version=N" to the raw configuration, it differs on every node
+func stripVersionLine(raw string) string {
+	lines := strings.SplitN(raw, "\n", 2)
+	if strings.HasPrefix(lines[0], "# _version") {
+		if len(lines) == 1 {
+			return ""
+		}
+		return lines[1]
+	}
+	return raw
+}
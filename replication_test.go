@@ -0,0 +1,50 @@
+package haproxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStripVersionLine(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"version line", "# _version=42\nglobal\n  daemon\n", "global\n  daemon\n"},
+		{"only the version line", "# _version=42", ""},
+		{"no version line", "global\n  daemon\n", "global\n  daemon\n"},
+		{"version later in the file is kept", "global\n# _version=42\n", "global\n# _version=42\n"},
+		{"empty", "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := stripVersionLine(test.raw); got != test.want {
+				t.Fatalf("%q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReplicatorBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{"first failure", time.Second, 1, time.Second},
+		{"doubled", time.Second, 3, 4 * time.Second},
+		{"capped", time.Second, 5, 10 * time.Second},
+		{"stays capped", time.Second, 50, 10 * time.Second},
+		{"default interval", 0, 2, 2 * DefaultReplicationInterval},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := NewHaproxyReplicator(nil, nil, test.interval)
+			if got := r.backoff(test.failures); got != test.want {
+				t.Fatalf("backoff after %d failures %v, want %v", test.failures, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package haproxy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// a path routed to a backend: a path_beg (or path when Exact) acl on the frontend, a backend switching
+// rule using it and, when Rewrite is set, a replace-path http-request rule replacing the matched prefix.
+//
+// The rewrite rule is added to the backend: the http-request rules of the frontend run before the
+// switching rules, a rewrite there would change the path the routes are matched against. A route with a
+// rewrite can't cover a more specific route of the same backend rewriting differently, it would rewrite
+// its requests too.
+type HaproxyRoute struct {
+	Path    string `json:"path"`
+	Exact   bool   `json:"exact,omitempty"`
+	Backend string `json:"backend"`
+	// the prefix replacing Path before forwarding, eg: Path "/api" and Rewrite "/" turn /api/users into /users
+	Rewrite string `json:"rewrite,omitempty"`
+	Acl     string `json:"acl,omitempty"` // the acl name, derived from the path when empty, eg: path_api_v1
+}
+
+// manages the path routes of a frontend, the switching rules of the routes are kept together and
+// ordered longest path first so the most specific route wins
+type HaproxyRoutes struct {
+	Client   IHaproxyClient
+	Frontend string
+}
+
+func NewHaproxyRoutes(client IHaproxyClient, frontend string) *HaproxyRoutes {
+	return &HaproxyRoutes{Client: client, Frontend: frontend}
+}
+
+var routeIdentifier = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+func (r *HaproxyRoute) aclName() string {
+	if r.Acl != "" {
+		return r.Acl
+	}
+	id := strings.Trim(routeIdentifier.ReplaceAllString(r.Path, "_"), "_")
+	if id == "" {
+		id = "root"
+	}
+	if r.Exact {
+		return "path_exact_" + id
+	}
+	return "path_" + id
+}
+
+func (r *HaproxyRoute) criterion() string {
+	if r.Exact {
+		return "path"
+	}
+	return "path_beg"
+}
+
+// the anonymous acl matching the route, used by the rewrite rule since the backend doesn't see the
+// acls of the frontend
+func (r *HaproxyRoute) condition() string {
+	return "{ " + r.criterion() + " " + r.Path + " }"
+}
+
+func (r *HaproxyRoute) rewriteRule() HaproxyAddHttpRequestRule {
+	rule := HaproxyAddHttpRequestRule{Type: HttpRequestRuleReplacePath, Cond: CondIf, CondTest: r.condition()}
+	if r.Exact {
+		rule.PathMatch = "^" + regexp.QuoteMeta(r.Path) + "$"
+		rule.PathFmt = r.Rewrite
+		return rule
+	}
+	rule.PathMatch = "^" + regexp.QuoteMeta(strings.TrimSuffix(r.Path, "/")) + "/?(.*)$"
+	rule.PathFmt = strings.TrimSuffix(r.Rewrite, "/") + `/\1`
+	return rule
+}
+
+// the rewrite prefix of a replace-path rule built by rewriteRule
+func rewriteOf(rule HaproxyAddHttpRequestRule, exact bool) string {
+	if exact {
+		return rule.PathFmt
+	}
+	rewrite := strings.TrimSuffix(rule.PathFmt, `\1`)
+	if rewrite == "/" {
+		return rewrite
+	}
+	return strings.TrimSuffix(rewrite, "/")
+}
+
+// whether the route matches every request of the other one
+func (r *HaproxyRoute) covers(other *HaproxyRoute) bool {
+	if r.Exact {
+		return other.Exact && other.Path == r.Path
+	}
+	return strings.HasPrefix(other.Path, r.Path)
+}
+
+// the rewrite rules of a backend all see its requests: the rule of a route would also rewrite the
+// requests of a more specific route of the same backend which rewrites them differently, or not at all
+func conflictingRewrites(a *HaproxyRoute, b *HaproxyRoute) bool {
+	if a.Backend != b.Backend || a.Rewrite == b.Rewrite {
+		return false
+	}
+	return (a.Rewrite != "" && a.covers(b)) || (b.Rewrite != "" && b.covers(a))
+}
+
+// longest path first, an exact path before a prefix of the same length
+func sortRoutes(routes []HaproxyRoute) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		if len(routes[i].Path) != len(routes[j].Path) {
+			return len(routes[i].Path) > len(routes[j].Path)
+		}
+		if routes[i].Exact != routes[j].Exact {
+			return routes[i].Exact
+		}
+		return routes[i].Path < routes[j].Path
+	})
+}
+
+// the live acls and switching rules of the frontend, and the http-request rules of the routed backends
+type routeState struct {
+	acls     []HaproxyAddAcl
+	rules    []HaproxyAddBackendSwitchingRule
+	requests map[string][]HaproxyAddHttpRequestRule
+}
+
+func (m *HaproxyRoutes) fetch() (*routeState, error) {
+	acls, err := m.Client.GetAcls("frontend", m.Frontend)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := m.Client.GetBackendSwitchingRules(m.Frontend)
+	if err != nil {
+		return nil, err
+	}
+	state := routeState{acls: acls.Data, rules: rules.Data, requests: map[string][]HaproxyAddHttpRequestRule{}}
+	for _, route := range state.routes() {
+		if err := m.fetchRequests(&state, route.Backend); err != nil {
+			return nil, err
+		}
+	}
+	return &state, nil
+}
+
+func (m *HaproxyRoutes) fetchRequests(state *routeState, backend string) error {
+	if _, exist := state.requests[backend]; exist {
+		return nil
+	}
+	requests, err := m.Client.GetHttpRequestRules("backend", backend)
+	if err != nil {
+		return err
+	}
+	state.requests[backend] = requests.Data
+	return nil
+}
+
+// the routes found in the state: every path_beg or path acl used alone by an "if" switching rule
+func (s *routeState) routes() []HaproxyRoute {
+	acls := map[string]HaproxyAddAcl{}
+	for _, acl := range s.acls {
+		if acl.Criterion == "path_beg" || acl.Criterion == "path" {
+			acls[acl.AclName] = acl
+		}
+	}
+	routes := []HaproxyRoute{}
+	for _, rule := range s.rules {
+		acl, exist := acls[strings.TrimSpace(rule.CondTest)]
+		if rule.Cond != CondIf || !exist {
+			continue
+		}
+		route := HaproxyRoute{Path: strings.TrimSpace(acl.Value), Exact: acl.Criterion == "path", Backend: rule.Name, Acl: acl.AclName}
+		for _, request := range s.requests[route.Backend] {
+			if request.Type == HttpRequestRuleReplacePath && request.Cond == CondIf && strings.TrimSpace(request.CondTest) == route.condition() {
+				route.Rewrite = rewriteOf(request, route.Exact)
+			}
+		}
+		routes = append(routes, route)
+		delete(acls, acl.AclName)
+	}
+	return routes
+}
+
+// the routes of the frontend, in their matching order
+func (m *HaproxyRoutes) List() ([]HaproxyRoute, error) {
+	state, err := m.fetch()
+	if err != nil {
+		return nil, err
+	}
+	return state.routes(), nil
+}
+
+// add the route or update the one with the same path and exactness
+func (m *HaproxyRoutes) Apply(route HaproxyRoute) error {
+	state, err := m.fetch()
+	if err != nil {
+		return err
+	}
+	routes := []HaproxyRoute{}
+	for _, current := range state.routes() {
+		if current.Path != route.Path || current.Exact != route.Exact {
+			routes = append(routes, current)
+		}
+	}
+	return m.set(state, append(routes, route))
+}
+
+// remove the routes of the path, both prefix and exact
+func (m *HaproxyRoutes) Remove(path string) error {
+	state, err := m.fetch()
+	if err != nil {
+		return err
+	}
+	routes := []HaproxyRoute{}
+	for _, current := range state.routes() {
+		if current.Path != path {
+			routes = append(routes, current)
+		}
+	}
+	if len(routes) == len(state.routes()) {
+		return fmt.Errorf("route %s not found in frontend %s", path, m.Frontend)
+	}
+	return m.set(state, routes)
+}
+
+// replace all the routes of the frontend
+func (m *HaproxyRoutes) Set(routes []HaproxyRoute) error {
+	state, err := m.fetch()
+	if err != nil {
+		return err
+	}
+	return m.set(state, routes)
+}
+
+// rewrite the acls, switching rules and backend http-request rules of the routes in one transaction,
+// each group is placed where the first one of the routes currently is (at the end otherwise)
+func (m *HaproxyRoutes) set(state *routeState, routes []HaproxyRoute) error {
+	backends, err := m.Client.GetBackends()
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, backend := range backends.Data {
+		known[backend.Name] = true
+	}
+	seen := map[string]bool{}
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("route path %q must start with /", route.Path)
+		}
+		if !known[route.Backend] {
+			return fmt.Errorf("route %s: backend %s not found", route.Path, route.Backend)
+		}
+		if seen[route.aclName()] {
+			return fmt.Errorf("route %s: duplicate acl %s", route.Path, route.aclName())
+		}
+		seen[route.aclName()] = true
+	}
+	for i := range routes {
+		for j := i + 1; j < len(routes); j++ {
+			if conflictingRewrites(&routes[i], &routes[j]) {
+				return fmt.Errorf("routes %s and %s of backend %s overlap with different rewrites", routes[i].Path, routes[j].Path, routes[i].Backend)
+			}
+		}
+	}
+	routes = append([]HaproxyRoute{}, routes...)
+	sortRoutes(routes)
+
+	managed := map[string]bool{}
+	// the rewrite conditions of the old and new routes, per backend
+	conditions := map[string]map[string]bool{}
+	for _, route := range append(state.routes(), routes...) {
+		managed[route.aclName()] = true
+		if conditions[route.Backend] == nil {
+			conditions[route.Backend] = map[string]bool{}
+		}
+		conditions[route.Backend][route.condition()] = true
+		if err := m.fetchRequests(state, route.Backend); err != nil {
+			return err
+		}
+	}
+
+	routeAcls := []HaproxyAddAcl{}
+	switching := []HaproxyAddBackendSwitchingRule{}
+	for _, route := range routes {
+		routeAcls = append(routeAcls, HaproxyAddAcl{AclName: route.aclName(), Criterion: route.criterion(), Value: route.Path})
+		switching = append(switching, HaproxyAddBackendSwitchingRule{Cond: CondIf, CondTest: route.aclName(), Name: route.Backend})
+	}
+	rewrites := map[string][]HaproxyAddHttpRequestRule{}
+	for _, route := range routes {
+		if route.Rewrite != "" {
+			rewrites[route.Backend] = append(rewrites[route.Backend], route.rewriteRule())
+		}
+	}
+	acls := spliceRules(state.acls, routeAcls, func(acl HaproxyAddAcl) bool { return managed[acl.AclName] })
+	rules := spliceRules(state.rules, switching, func(rule HaproxyAddBackendSwitchingRule) bool {
+		return rule.Cond == CondIf && managed[strings.TrimSpace(rule.CondTest)]
+	})
+	requests := map[string][]HaproxyAddHttpRequestRule{}
+	for backend := range conditions {
+		requests[backend] = spliceRules(state.requests[backend], rewrites[backend], func(rule HaproxyAddHttpRequestRule) bool {
+			return rule.Type == HttpRequestRuleReplacePath && rule.Cond == CondIf && conditions[backend][strings.TrimSpace(rule.CondTest)]
+		})
+	}
+
+	return RunTransaction(m.Client, func(client IHaproxyClient, transactionId string) error {
+		if _, err := client.ReorderAcls("frontend", m.Frontend, transactionId, acls); err != nil {
+			return err
+		}
+		if _, err := client.ReorderBackendSwitchingRules(m.Frontend, transactionId, rules); err != nil {
+			return err
+		}
+		for _, backend := range sortedKeys(requests) {
+			if _, err := client.ReorderHttpRequestRules("backend", backend, transactionId, requests[backend]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// replace the managed rules of current by the given ones, inserted where the first managed rule was
+func spliceRules[T any](current []T, rules []T, managed func(rule T) bool) []T {
+	result := []T{}
+	inserted := false
+	for _, rule := range current {
+		if !managed(rule) {
+			result = append(result, rule)
+			continue
+		}
+		if !inserted {
+			result = append(result, rules...)
+			inserted = true
+		}
+	}
+	if !inserted {
+		result = append(result, rules...)
+	}
+	return result
+}
@@ -0,0 +1,138 @@
+package haproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSortRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []HaproxyRoute
+		want   []string
+	}{
+		{"longest first", []HaproxyRoute{{Path: "/"}, {Path: "/api/v1"}, {Path: "/api"}}, []string{"/api/v1", "/api", "/"}},
+		{"exact before prefix", []HaproxyRoute{{Path: "/api"}, {Path: "/api", Exact: true}}, []string{"=/api", "/api"}},
+		{"same length by path", []HaproxyRoute{{Path: "/web"}, {Path: "/api"}}, []string{"/api", "/web"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sortRoutes(test.routes)
+			got := []string{}
+			for _, route := range test.routes {
+				if route.Exact {
+					got = append(got, "="+route.Path)
+				} else {
+					got = append(got, route.Path)
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("order %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSpliceRules(t *testing.T) {
+	managed := func(rule string) bool { return strings.HasPrefix(rule, "route") }
+	tests := []struct {
+		name    string
+		current []string
+		rules   []string
+		want    []string
+	}{
+		{"no rules yet", nil, []string{"route1"}, []string{"route1"}},
+		{"appended after the other rules", []string{"a", "b"}, []string{"route1"}, []string{"a", "b", "route1"}},
+		{"in place of the first managed rule", []string{"a", "route1", "b", "route2", "c"}, []string{"route3", "route1"}, []string{"a", "route3", "route1", "b", "c"}},
+		{"all removed", []string{"a", "route1", "b"}, nil, []string{"a", "b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := spliceRules(test.current, test.rules, managed); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("rules %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// a frontend without routes, recording whether a transaction was started
+type fakeRoutesNode struct {
+	IHaproxyClient
+	started bool
+}
+
+func (n *fakeRoutesNode) GetAcls(parentType string, parentName string) (*HaproxyAcls, error) {
+	return &HaproxyAcls{}, nil
+}
+
+func (n *fakeRoutesNode) GetBackendSwitchingRules(frontend string) (*HaproxyBackendSwitchingRules, error) {
+	return &HaproxyBackendSwitchingRules{}, nil
+}
+
+func (n *fakeRoutesNode) GetHttpRequestRules(parentType string, parentName string) (*HaproxyHttpRequestRules, error) {
+	return &HaproxyHttpRequestRules{}, nil
+}
+
+func (n *fakeRoutesNode) GetBackends() (*HaproxyBackends, error) {
+	backends := HaproxyBackends{}
+	if err := json.Unmarshal([]byte(`{"data":[{"name":"api"},{"name":"web"}]}`), &backends); err != nil {
+		return nil, err
+	}
+	return &backends, nil
+}
+
+func (n *fakeRoutesNode) GetConfigurationVersion() (*int, error) {
+	n.started = true
+	return nil, errors.New("transaction started")
+}
+
+func TestRoutesSetConflictingRewrites(t *testing.T) {
+	tests := []struct {
+		name     string
+		routes   []HaproxyRoute
+		conflict bool
+	}{
+		{
+			"rewrite of a prefix covering a route without rewrite",
+			[]HaproxyRoute{{Path: "/api", Backend: "api", Rewrite: "/"}, {Path: "/api/v2", Backend: "api"}},
+			true,
+		},
+		{
+			"different rewrites of the same path",
+			[]HaproxyRoute{{Path: "/api", Backend: "api", Rewrite: "/"}, {Path: "/api", Exact: true, Backend: "api", Rewrite: "/index"}},
+			true,
+		},
+		{
+			"same rewrite",
+			[]HaproxyRoute{{Path: "/api", Backend: "api", Rewrite: "/"}, {Path: "/api/v2", Backend: "api", Rewrite: "/"}},
+			false,
+		},
+		{
+			"distinct paths",
+			[]HaproxyRoute{{Path: "/api", Backend: "api", Rewrite: "/"}, {Path: "/v2", Backend: "api"}},
+			false,
+		},
+		{
+			"rewrite of an exact route inside a prefix without rewrite",
+			[]HaproxyRoute{{Path: "/api/health", Exact: true, Backend: "api", Rewrite: "/health"}, {Path: "/api", Backend: "api"}},
+			false,
+		},
+		{
+			"other backends",
+			[]HaproxyRoute{{Path: "/api", Backend: "api", Rewrite: "/"}, {Path: "/api/web", Backend: "web"}},
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := &fakeRoutesNode{}
+			err := NewHaproxyRoutes(node, "www").Set(test.routes)
+			if test.conflict != (err != nil && !node.started) {
+				t.Fatalf("error %v, transaction started %v", err, node.started)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package haproxy
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type RuleOperationType string
+
+const (
+	RuleOperationInsert  RuleOperationType = "insert"
+	RuleOperationReplace RuleOperationType = "replace"
+	RuleOperationDelete  RuleOperationType = "delete"
+)
+
+// a single index based step of a reordering, the steps must be applied in the order they are returned
+// since every insert or delete shifts the indexes of the following rules.
+//
+// Rule is nil for deletes, Current is the rule being replaced or deleted.
+type RuleOperation[T any] struct {
+	Type    RuleOperationType `json:"type"`
+	Index   int               `json:"index"`
+	Rule    *T                `json:"rule,omitempty"`
+	Current *T                `json:"current,omitempty"`
+}
+
+// compute the minimal sequence of inserts, replaces and deletes turning the current rules of a parent
+// into the desired ordered list.
+//
+// T is one of the HaproxyAdd* rule models (acls, http/tcp rules, switching rules): the Index field
+// is ignored when comparing and is set on each returned rule to the position it must be written at.
+//
+// example usage:
+//
+// ops := haproxy.PlanRuleOrder(acls.Data, []haproxy.HaproxyAddAcl{{AclName: "is_api", Criterion: "path_beg", Value: "/api"}})
+func PlanRuleOrder[T any](current []T, desired []T) []RuleOperation[T] {
+	n, m := len(current), len(desired)
+	// cost[i][j] is the number of operations turning current[i:] into desired[j:]
+	cost := make([][]int, n+1)
+	for i := range cost {
+		cost[i] = make([]int, m+1)
+	}
+	for i := n; i >= 0; i-- {
+		for j := m; j >= 0; j-- {
+			switch {
+			case i == n:
+				cost[i][j] = m - j
+			case j == m:
+				cost[i][j] = n - i
+			default:
+				best := cost[i+1][j+1] + 1
+				if sameRule(current[i], desired[j]) {
+					best = cost[i+1][j+1]
+				}
+				if cost[i+1][j]+1 < best {
+					best = cost[i+1][j] + 1
+				}
+				if cost[i][j+1]+1 < best {
+					best = cost[i][j+1] + 1
+				}
+				cost[i][j] = best
+			}
+		}
+	}
+
+	ops := []RuleOperation[T]{}
+	i, j, pos := 0, 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && sameRule(current[i], desired[j]) && cost[i][j] == cost[i+1][j+1]:
+			i, j, pos = i+1, j+1, pos+1
+		case i < n && j < m && cost[i][j] == cost[i+1][j+1]+1:
+			ops = append(ops, RuleOperation[T]{Type: RuleOperationReplace, Index: pos, Rule: ruleAt(desired[j], pos), Current: ruleAt(current[i], pos)})
+			i, j, pos = i+1, j+1, pos+1
+		case i < n && cost[i][j] == cost[i+1][j]+1:
+			ops = append(ops, RuleOperation[T]{Type: RuleOperationDelete, Index: pos, Current: ruleAt(current[i], pos)})
+			i++
+		default:
+			ops = append(ops, RuleOperation[T]{Type: RuleOperationInsert, Index: pos, Rule: ruleAt(desired[j], pos)})
+			j, pos = j+1, pos+1
+		}
+	}
+	return ops
+}
+
+// apply the operations computed by PlanRuleOrder through the given index based calls
+func applyRuleOperations[T any](ops []RuleOperation[T], insert func(rule *T) error, replace func(index int, rule *T) error, remove func(index int) error) error {
+	for _, op := range ops {
+		var err error
+		switch op.Type {
+		case RuleOperationInsert:
+			err = insert(op.Rule)
+		case RuleOperationReplace:
+			err = replace(op.Index, op.Rule)
+		case RuleOperationDelete:
+			err = remove(op.Index)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compares two rules regardless of their Index
+func sameRule[T any](a T, b T) bool {
+	return reflect.DeepEqual(ruleAt(a, 0), ruleAt(b, 0))
+}
+
+// returns a copy of the rule with its Index field set
+func ruleAt[T any](rule T, index int) *T {
+	field := reflect.ValueOf(&rule).Elem().FieldByName("Index")
+	if field.IsValid() && field.CanSet() {
+		field.SetInt(int64(index))
+	}
+	return &rule
+}
+
+// reorder the acls of a parent (backend or frontend) within the given transaction, returns the applied operations
+func (h *haproxyClient) ReorderAcls(parentType string, parentName string, transactionId string, desired []HaproxyAddAcl) ([]RuleOperation[HaproxyAddAcl], error) {
+	current := HaproxyAcls{}
+	if err := h.transactionRules(fmt.Sprintf("acls?parent_type=%s&parent_name=%s", parentType, parentName), transactionId, &current); err != nil {
+		return nil, err
+	}
+	ops := PlanRuleOrder(current.Data, desired)
+	err := applyRuleOperations(ops,
+		func(rule *HaproxyAddAcl) error { return h.AddAcl(parentType, parentName, transactionId, rule) },
+		func(index int, rule *HaproxyAddAcl) error {
+			return h.ReplaceAcl(parentType, parentName, index, transactionId, rule)
+		},
+		func(index int) error { return h.DeleteAcl(parentType, parentName, index, transactionId) })
+	return ops, err
+}
+
+func (h *haproxyClient) ReorderHttpRequestRules(parentType string, parentName string, transactionId string, desired []HaproxyAddHttpRequestRule) ([]RuleOperation[HaproxyAddHttpRequestRule], error) {
+	current := HaproxyHttpRequestRules{}
+	if err := h.transactionRules(fmt.Sprintf("http_request_rules?parent_type=%s&parent_name=%s", parentType, parentName), transactionId, &current); err != nil {
+		return nil, err
+	}
+	ops := PlanRuleOrder(current.Data, desired)
+	err := applyRuleOperations(ops,
+		func(rule *HaproxyAddHttpRequestRule) error {
+			return h.AddHttpRequestRule(parentType, parentName, transactionId, rule)
+		},
+		func(index int, rule *HaproxyAddHttpRequestRule) error {
+			return h.ReplaceHttpRequestRule(parentType, parentName, index, transactionId, rule)
+		},
+		func(index int) error { return h.DeleteHttpRequestRule(parentType, parentName, index, transactionId) })
+	return ops, err
+}
+
+func (h *haproxyClient) ReorderHttpResponseRules(parentType string, parentName string, transactionId string, desired []HaproxyAddHttpResponseRule) ([]RuleOperation[HaproxyAddHttpResponseRule], error) {
+	current := HaproxyHttpResponseRules{}
+	if err := h.transactionRules(fmt.Sprintf("http_response_rules?parent_type=%s&parent_name=%s", parentType, parentName), transactionId, &current); err != nil {
+		return nil, err
+	}
+	ops := PlanRuleOrder(current.Data, desired)
+	err := applyRuleOperations(ops,
+		func(rule *HaproxyAddHttpResponseRule) error {
+			return h.AddHttpResponseRule(parentType, parentName, transactionId, rule)
+		},
+		func(index int, rule *HaproxyAddHttpResponseRule) error {
+			return h.ReplaceHttpResponseRule(parentType, parentName, index, transactionId, rule)
+		},
+		func(index int) error { return h.DeleteHttpResponseRule(parentType, parentName, index, transactionId) })
+	return ops, err
+}
+
+func (h *haproxyClient) ReorderTcpRequestRules(parentType string, parentName string, transactionId string, desired []HaproxyAddTcpRequestRule) ([]RuleOperation[HaproxyAddTcpRequestRule], error) {
+	current := HaproxyTcpRequestRules{}
+	if err := h.transactionRules(fmt.Sprintf("tcp_request_rules?parent_type=%s&parent_name=%s", parentType, parentName), transactionId, &current); err != nil {
+		return nil, err
+	}
+	ops := PlanRuleOrder(current.Data, desired)
+	err := applyRuleOperations(ops,
+		func(rule *HaproxyAddTcpRequestRule) error {
+			return h.AddTcpRequestRule(parentType, parentName, transactionId, rule)
+		},
+		func(index int, rule *HaproxyAddTcpRequestRule) error {
+			return h.ReplaceTcpRequestRule(parentType, parentName, index, transactionId, rule)
+		},
+		func(index int) error { return h.DeleteTcpRequestRule(parentType, parentName, index, transactionId) })
+	return ops, err
+}
+
+func (h *haproxyClient) ReorderTcpResponseRules(backend string, transactionId string, desired []HaproxyAddTcpResponseRule) ([]RuleOperation[HaproxyAddTcpResponseRule], error) {
+	current := HaproxyTcpResponseRules{}
+	if err := h.transactionRules("tcp_response_rules?backend="+backend, transactionId, &current); err != nil {
+		return nil, err
+	}
+	ops := PlanRuleOrder(current.Data, desired)
+	err := applyRuleOperations(ops,
+		func(rule *HaproxyAddTcpResponseRule) error { return h.AddTcpResponseRule(backend, transactionId, rule) },
+		func(index int, rule *HaproxyAddTcpResponseRule) error {
+			return h.ReplaceTcpResponseRule(backend, index, transactionId, rule)
+		},
+		func(index int) error { return h.DeleteTcpResponseRule(backend, index, transactionId) })
+	return ops, err
+}
+
+func (h *haproxyClient) ReorderBackendSwitchingRules(frontend string, transactionId string, desired []HaproxyAddBackendSwitchingRule) ([]RuleOperation[HaproxyAddBackendSwitchingRule], error) {
+	current := HaproxyBackendSwitchingRules{}
+	if err := h.transactionRules("backend_switching_rules?frontend="+frontend, transactionId, &current); err != nil {
+		return nil, err
+	}
+	ops := PlanRuleOrder(current.Data, desired)
+	err := applyRuleOperations(ops,
+		func(rule *HaproxyAddBackendSwitchingRule) error {
+			return h.AddBackendSwitchingRule(frontend, transactionId, rule)
+		},
+		func(index int, rule *HaproxyAddBackendSwitchingRule) error {
+			return h.ReplaceBackendSwitchingRule(frontend, index, transactionId, rule)
+		},
+		func(index int) error { return h.DeleteBackendSwitchingRule(frontend, index, transactionId) })
+	return ops, err
+}
+
+func (h *haproxyClient) ReorderServerSwitchingRules(backend string, transactionId string, desired []HaproxyAddServerSwitchingRule) ([]RuleOperation[HaproxyAddServerSwitchingRule], error) {
+	current := HaproxyServerSwitchingRules{}
+	if err := h.transactionRules("server_switching_rules?backend="+backend, transactionId, &current); err != nil {
+		return nil, err
+	}
+	ops := PlanRuleOrder(current.Data, desired)
+	err := applyRuleOperations(ops,
+		func(rule *HaproxyAddServerSwitchingRule) error {
+			return h.AddServerSwitchingRule(backend, transactionId, rule)
+		},
+		func(index int, rule *HaproxyAddServerSwitchingRule) error {
+			return h.ReplaceServerSwitchingRule(backend, index, transactionId, rule)
+		},
+		func(index int) error { return h.DeleteServerSwitchingRule(backend, index, transactionId) })
+	return ops, err
+}
+
+// read the rules list selected by query (eg: "acls?parent_type=frontend&parent_name=http") as the
+// transaction sees it, the rules written earlier in the same transaction included
+func (h *haproxyClient) transactionRules(query string, transactionId string, rules interface{}) error {
+	url := h.Url + fmt.Sprintf("/v2/services/haproxy/configuration/%s&transaction_id=%s", query, transactionId)
+	resp, err := h.Rest.R().
+		SetHeader("Accept", "application/json").
+		SetError(&HaproxyErrorResponse{}).
+		SetResult(rules).Get(url)
+	if err != nil {
+		return err
+	}
+	return responseError(resp)
+}
@@ -0,0 +1,86 @@
+package haproxy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func acl(name string, value string) HaproxyAddAcl {
+	return HaproxyAddAcl{AclName: name, Criterion: "path_beg", Value: value}
+}
+
+func TestPlanRuleOrder(t *testing.T) {
+	a, b, c, d := acl("a", "/a"), acl("b", "/b"), acl("c", "/c"), acl("d", "/d")
+	tests := []struct {
+		name    string
+		current []HaproxyAddAcl
+		desired []HaproxyAddAcl
+		types   []RuleOperationType
+	}{
+		{"unchanged", []HaproxyAddAcl{a, b, c}, []HaproxyAddAcl{a, b, c}, []RuleOperationType{}},
+		{"empty to rules", nil, []HaproxyAddAcl{a, b}, []RuleOperationType{RuleOperationInsert, RuleOperationInsert}},
+		{"rules to empty", []HaproxyAddAcl{a, b}, nil, []RuleOperationType{RuleOperationDelete, RuleOperationDelete}},
+		{"insert in the middle", []HaproxyAddAcl{a, c}, []HaproxyAddAcl{a, b, c}, []RuleOperationType{RuleOperationInsert}},
+		{"delete in the middle", []HaproxyAddAcl{a, b, c}, []HaproxyAddAcl{a, c}, []RuleOperationType{RuleOperationDelete}},
+		{"replace one", []HaproxyAddAcl{a, b, c}, []HaproxyAddAcl{a, d, c}, []RuleOperationType{RuleOperationReplace}},
+		{"swap", []HaproxyAddAcl{a, b}, []HaproxyAddAcl{b, a}, []RuleOperationType{RuleOperationReplace, RuleOperationReplace}},
+		{"move first to last", []HaproxyAddAcl{a, b, c}, []HaproxyAddAcl{b, c, a}, []RuleOperationType{RuleOperationDelete, RuleOperationInsert}},
+		{"index ignored", []HaproxyAddAcl{{AclName: "a", Criterion: "path_beg", Value: "/a", Index: 4}}, []HaproxyAddAcl{a}, []RuleOperationType{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ops := PlanRuleOrder(test.current, test.desired)
+			types := []RuleOperationType{}
+			for _, op := range ops {
+				types = append(types, op.Type)
+			}
+			if !reflect.DeepEqual(types, test.types) {
+				t.Fatalf("operations %v, want %v", types, test.types)
+			}
+
+			// replaying the operations on the current rules gives the desired ones
+			rules := append([]HaproxyAddAcl{}, test.current...)
+			err := applyRuleOperations(ops,
+				func(rule *HaproxyAddAcl) error {
+					rules = append(rules[:rule.Index], append([]HaproxyAddAcl{*rule}, rules[rule.Index:]...)...)
+					return nil
+				},
+				func(index int, rule *HaproxyAddAcl) error {
+					rules[index] = *rule
+					return nil
+				},
+				func(index int) error {
+					rules = append(rules[:index], rules[index+1:]...)
+					return nil
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rules) != len(test.desired) {
+				t.Fatalf("%d rules, want %d", len(rules), len(test.desired))
+			}
+			for i := range rules {
+				if !sameRule(rules[i], test.desired[i]) {
+					t.Fatalf("rule %d is %+v, want %+v", i, rules[i], test.desired[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApplyRuleOperationsStopsOnError(t *testing.T) {
+	failed := errors.New("conflict")
+	ops := PlanRuleOrder(nil, []HaproxyAddAcl{acl("a", "/a"), acl("b", "/b")})
+	calls := 0
+	err := applyRuleOperations(ops,
+		func(rule *HaproxyAddAcl) error {
+			calls++
+			return failed
+		},
+		func(index int, rule *HaproxyAddAcl) error { return nil },
+		func(index int) error { return nil })
+	if !errors.Is(err, failed) || calls != 1 {
+		t.Fatalf("err %v after %d calls, want %v after 1 call", err, calls, failed)
+	}
+}
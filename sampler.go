@@ -0,0 +1,322 @@
+package haproxy
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// the polling interval used when none is given
+const DefaultSampleInterval = 10 * time.Second
+
+// identifies the stats of a frontend, backend or server, Server is empty for frontends and backends
+type HaproxyStatKey struct {
+	Type   string `json:"type"`
+	Proxy  string `json:"proxy"`
+	Server string `json:"server,omitempty"`
+}
+
+func (k HaproxyStatKey) String() string {
+	if k.Server != "" {
+		return k.Type + " " + k.Proxy + "/" + k.Server
+	}
+	return k.Type + " " + k.Proxy
+}
+
+// the native stats of a proxy or server at a point in time, summed over the runtime apis
+type HaproxyStatSample struct {
+	Time   time.Time               `json:"time"`
+	Values HaproxyNativeStatValues `json:"values"`
+}
+
+// the increase of the cumulative counters between two samples
+type HaproxyStatDelta struct {
+	Requests         int `json:"requests"`
+	Responses        int `json:"responses"`
+	Responses4xx     int `json:"responses_4xx"`
+	Responses5xx     int `json:"responses_5xx"`
+	Sessions         int `json:"sessions"`
+	BytesIn          int `json:"bytes_in"`
+	BytesOut         int `json:"bytes_out"`
+	RequestErrors    int `json:"request_errors"`
+	ConnectionErrors int `json:"connection_errors"`
+	ResponseErrors   int `json:"response_errors"`
+}
+
+func (d *HaproxyStatDelta) add(other HaproxyStatDelta) {
+	d.Requests += other.Requests
+	d.Responses += other.Responses
+	d.Responses4xx += other.Responses4xx
+	d.Responses5xx += other.Responses5xx
+	d.Sessions += other.Sessions
+	d.BytesIn += other.BytesIn
+	d.BytesOut += other.BytesOut
+	d.RequestErrors += other.RequestErrors
+	d.ConnectionErrors += other.ConnectionErrors
+	d.ResponseErrors += other.ResponseErrors
+}
+
+// the rates of a proxy or server over the sampled window.
+//
+// Reset is true when a counter went backwards within the window (haproxy reloaded or the stats were
+// cleared), the counter is then assumed to have restarted from zero.
+type HaproxyStatRates struct {
+	Key          HaproxyStatKey          `json:"key"`
+	From         time.Time               `json:"from"`
+	To           time.Time               `json:"to"`
+	Delta        HaproxyStatDelta        `json:"delta"`
+	LastDelta    HaproxyStatDelta        `json:"last_delta"`     // the increase between the last two samples, whatever the window
+	RequestRate  float64                 `json:"request_rate"`   // requests per second
+	ErrorRatio   float64                 `json:"error_ratio"`    // 5xx responses over all responses, 0 when there were none
+	BytesInRate  float64                 `json:"bytes_in_rate"`  // bytes per second
+	BytesOutRate float64                 `json:"bytes_out_rate"` // bytes per second
+	Reset        bool                    `json:"reset"`
+	Current      HaproxyNativeStatValues `json:"current"` // the latest sample, for the gauges and the status
+}
+
+// polls the native stats on an interval and keeps the last Size samples of every proxy and server
+type HaproxyStatsSampler struct {
+	Client   IHaproxyClient
+	Interval time.Duration // DefaultSampleInterval when not positive
+	Size     int           // the samples kept per proxy and server
+
+	lock      sync.RWMutex
+	series    map[HaproxyStatKey]*sampleRing
+	lastErr   error
+	listeners []func(sampler *HaproxyStatsSampler)
+}
+
+// a fixed size ring buffer of samples, oldest first
+type sampleRing struct {
+	samples []HaproxyStatSample
+	start   int
+	count   int
+}
+
+func (r *sampleRing) push(sample HaproxyStatSample) {
+	if r.count < len(r.samples) {
+		r.samples[(r.start+r.count)%len(r.samples)] = sample
+		r.count++
+		return
+	}
+	r.samples[r.start] = sample
+	r.start = (r.start + 1) % len(r.samples)
+}
+
+func (r *sampleRing) list() []HaproxyStatSample {
+	list := make([]HaproxyStatSample, r.count)
+	for i := range list {
+		list[i] = r.samples[(r.start+i)%len(r.samples)]
+	}
+	return list
+}
+
+// create a sampler polling every interval (DefaultSampleInterval when not positive) and keeping
+// size samples per proxy and server (at least 2)
+func NewHaproxyStatsSampler(client IHaproxyClient, interval time.Duration, size int) *HaproxyStatsSampler {
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+	if size < 2 {
+		size = 2
+	}
+	return &HaproxyStatsSampler{
+		Client:   client,
+		Interval: interval,
+		Size:     size,
+		series:   map[HaproxyStatKey]*sampleRing{},
+	}
+}
+
+// register a function called after every successful sample
+func (s *HaproxyStatsSampler) OnSample(listener func(sampler *HaproxyStatsSampler)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// sample the stats until the context is done
+func (s *HaproxyStatsSampler) Run(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.SampleOnce()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// take a sample of every proxy and server, the proxies and servers missing from the stats are forgotten
+func (s *HaproxyStatsSampler) SampleOnce() error {
+	stats, err := s.Client.GetStats()
+	now := time.Now()
+	s.lock.Lock()
+	s.lastErr = err
+	if err != nil {
+		s.lock.Unlock()
+		return err
+	}
+	seen := map[HaproxyStatKey]bool{}
+	for _, stat := range stats.Merged() {
+		key := statKey(stat)
+		seen[key] = true
+		ring, exist := s.series[key]
+		if !exist {
+			ring = &sampleRing{samples: make([]HaproxyStatSample, s.Size)}
+			s.series[key] = ring
+		}
+		ring.push(HaproxyStatSample{Time: now, Values: stat.Stats})
+	}
+	for key := range s.series {
+		if !seen[key] {
+			delete(s.series, key)
+		}
+	}
+	listeners := s.listeners
+	s.lock.Unlock()
+
+	for _, listener := range listeners {
+		listener(s)
+	}
+	return nil
+}
+
+// the error of the last sample, nil when it succeeded
+func (s *HaproxyStatsSampler) Err() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.lastErr
+}
+
+// the sampled proxies and servers, sorted by type, proxy and server
+func (s *HaproxyStatsSampler) Keys() []HaproxyStatKey {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	keys := make([]HaproxyStatKey, 0, len(s.series))
+	for key := range s.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Type != keys[j].Type {
+			return keys[i].Type < keys[j].Type
+		}
+		if keys[i].Proxy != keys[j].Proxy {
+			return keys[i].Proxy < keys[j].Proxy
+		}
+		return keys[i].Server < keys[j].Server
+	})
+	return keys
+}
+
+// the samples of a proxy or server, oldest first
+func (s *HaproxyStatsSampler) Samples(key HaproxyStatKey) []HaproxyStatSample {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	ring, exist := s.series[key]
+	if !exist {
+		return nil
+	}
+	return ring.list()
+}
+
+// the rates of a proxy or server over the samples taken within window of the latest one,
+// a zero window uses the last two samples. false is returned until two samples are available.
+func (s *HaproxyStatsSampler) Rates(key HaproxyStatKey, window time.Duration) (HaproxyStatRates, bool) {
+	return computeRates(key, s.Samples(key), window)
+}
+
+// the rates of every sampled proxy and server over window, sorted as Keys
+func (s *HaproxyStatsSampler) AllRates(window time.Duration) []HaproxyStatRates {
+	all := []HaproxyStatRates{}
+	for _, key := range s.Keys() {
+		if rates, ok := s.Rates(key, window); ok {
+			all = append(all, rates)
+		}
+	}
+	return all
+}
+
+func computeRates(key HaproxyStatKey, samples []HaproxyStatSample, window time.Duration) (HaproxyStatRates, bool) {
+	if len(samples) < 2 {
+		return HaproxyStatRates{}, false
+	}
+	last := len(samples) - 1
+	first := last - 1
+	if window > 0 {
+		for first > 0 && !samples[first-1].Time.Before(samples[last].Time.Add(-window)) {
+			first--
+		}
+	}
+	rates := HaproxyStatRates{Key: key, From: samples[first].Time, To: samples[last].Time, Current: samples[last].Values}
+	for i := first + 1; i <= last; i++ {
+		delta, reset := statDelta(&samples[i-1].Values, &samples[i].Values)
+		rates.Delta.add(delta)
+		rates.LastDelta = delta
+		rates.Reset = rates.Reset || reset
+	}
+	if seconds := rates.To.Sub(rates.From).Seconds(); seconds > 0 {
+		rates.RequestRate = float64(rates.Delta.Requests) / seconds
+		rates.BytesInRate = float64(rates.Delta.BytesIn) / seconds
+		rates.BytesOutRate = float64(rates.Delta.BytesOut) / seconds
+	}
+	if rates.Delta.Responses > 0 {
+		rates.ErrorRatio = float64(rates.Delta.Responses5xx) / float64(rates.Delta.Responses)
+	}
+	return rates, true
+}
+
+// the counters increase between two consecutive samples, a counter lower than before restarted from zero
+func statDelta(previous *HaproxyNativeStatValues, current *HaproxyNativeStatValues) (HaproxyStatDelta, bool) {
+	reset := false
+	increase := func(before int, after int) int {
+		if after < before {
+			reset = true
+			return after
+		}
+		return after - before
+	}
+	delta := HaproxyStatDelta{
+		Requests:         increase(requestCount(previous), requestCount(current)),
+		Responses:        increase(responseCount(previous), responseCount(current)),
+		Responses4xx:     increase(previous.Hrsp4Xx, current.Hrsp4Xx),
+		Responses5xx:     increase(previous.Hrsp5Xx, current.Hrsp5Xx),
+		Sessions:         increase(previous.Stot, current.Stot),
+		BytesIn:          increase(previous.Bin, current.Bin),
+		BytesOut:         increase(previous.Bout, current.Bout),
+		RequestErrors:    increase(previous.Ereq, current.Ereq),
+		ConnectionErrors: increase(previous.Econ, current.Econ),
+		ResponseErrors:   increase(previous.Eresp, current.Eresp),
+	}
+	return delta, reset
+}
+
+func responseCount(v *HaproxyNativeStatValues) int {
+	return v.Hrsp1Xx + v.Hrsp2Xx + v.Hrsp3Xx + v.Hrsp4Xx + v.Hrsp5Xx + v.HrspOther
+}
+
+// req_total is only reported by frontends, backends and servers are counted by their responses,
+// or by their sessions in tcp mode
+func requestCount(v *HaproxyNativeStatValues) int {
+	if v.ReqTotal > 0 {
+		return v.ReqTotal
+	}
+	if v.Mode == "http" {
+		return responseCount(v)
+	}
+	return v.Stot
+}
+
+func statKey(stat HaproxyNativeStat) HaproxyStatKey {
+	if stat.Type == "server" {
+		return HaproxyStatKey{Type: stat.Type, Proxy: stat.BackendName, Server: stat.Name}
+	}
+	return HaproxyStatKey{Type: stat.Type, Proxy: stat.Name}
+}
@@ -0,0 +1,109 @@
+package haproxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatDelta(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous HaproxyNativeStatValues
+		current  HaproxyNativeStatValues
+		want     HaproxyStatDelta
+		reset    bool
+	}{
+		{
+			"increase",
+			HaproxyNativeStatValues{ReqTotal: 100, Hrsp2Xx: 90, Hrsp5Xx: 10, Bin: 1000, Stot: 5},
+			HaproxyNativeStatValues{ReqTotal: 150, Hrsp2Xx: 130, Hrsp5Xx: 20, Bin: 1500, Stot: 7},
+			HaproxyStatDelta{Requests: 50, Responses: 50, Responses5xx: 10, BytesIn: 500, Sessions: 2},
+			false,
+		},
+		{
+			"counter reset on reload",
+			HaproxyNativeStatValues{ReqTotal: 100, Hrsp2Xx: 100, Bin: 1000},
+			HaproxyNativeStatValues{ReqTotal: 30, Hrsp2Xx: 30, Bin: 300},
+			HaproxyStatDelta{Requests: 30, Responses: 30, BytesIn: 300},
+			true,
+		},
+		{
+			"backends are counted by responses in http mode",
+			HaproxyNativeStatValues{Mode: "http", Hrsp2Xx: 10, Hrsp4Xx: 1, Stot: 3},
+			HaproxyNativeStatValues{Mode: "http", Hrsp2Xx: 20, Hrsp4Xx: 3, Stot: 5},
+			HaproxyStatDelta{Requests: 12, Responses: 12, Responses4xx: 2, Sessions: 2},
+			false,
+		},
+		{
+			"and by sessions in tcp mode",
+			HaproxyNativeStatValues{Mode: "tcp", Stot: 3},
+			HaproxyNativeStatValues{Mode: "tcp", Stot: 8},
+			HaproxyStatDelta{Requests: 5, Sessions: 5},
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delta, reset := statDelta(&test.previous, &test.current)
+			if delta != test.want || reset != test.reset {
+				t.Fatalf("delta %+v reset %v, want %+v reset %v", delta, reset, test.want, test.reset)
+			}
+		})
+	}
+}
+
+func TestComputeRates(t *testing.T) {
+	start := time.Date(2022, 10, 21, 12, 0, 0, 0, time.UTC)
+	sample := func(seconds int, requests int, errors int) HaproxyStatSample {
+		return HaproxyStatSample{
+			Time:   start.Add(time.Duration(seconds) * time.Second),
+			Values: HaproxyNativeStatValues{ReqTotal: requests, Hrsp2Xx: requests - errors, Hrsp5Xx: errors},
+		}
+	}
+	samples := []HaproxyStatSample{sample(0, 0, 0), sample(10, 100, 0), sample(20, 300, 10), sample(30, 400, 30)}
+	tests := []struct {
+		name        string
+		samples     []HaproxyStatSample
+		window      time.Duration
+		ok          bool
+		requests    int
+		lastDelta   int
+		requestRate float64
+		errorRatio  float64
+		reset       bool
+	}{
+		{"not enough samples", samples[:1], 0, false, 0, 0, 0, 0, false},
+		{"last two samples", samples, 0, true, 100, 100, 10, 0.2, false},
+		{"window", samples, 20 * time.Second, true, 300, 100, 15, 0.1, false},
+		{"window longer than the samples", samples, time.Hour, true, 400, 100, 400.0 / 30, 30.0 / 400, false},
+		{"reset within the window", append(samples[:3:3], sample(30, 50, 5)), 20 * time.Second, true, 250, 50, 12.5, 15.0 / 250, true},
+	}
+	key := HaproxyStatKey{Type: "frontend", Proxy: "www"}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rates, ok := computeRates(key, test.samples, test.window)
+			if ok != test.ok {
+				t.Fatalf("ok %v, want %v", ok, test.ok)
+			}
+			if !ok {
+				return
+			}
+			if rates.Delta.Requests != test.requests || rates.LastDelta.Requests != test.lastDelta {
+				t.Fatalf("%d requests, %d in the last delta, want %d and %d", rates.Delta.Requests, rates.LastDelta.Requests, test.requests, test.lastDelta)
+			}
+			if !closeTo(rates.RequestRate, test.requestRate) || !closeTo(rates.ErrorRatio, test.errorRatio) {
+				t.Fatalf("request rate %v, error ratio %v, want %v and %v", rates.RequestRate, rates.ErrorRatio, test.requestRate, test.errorRatio)
+			}
+			if rates.Reset != test.reset {
+				t.Fatalf("reset %v, want %v", rates.Reset, test.reset)
+			}
+			if rates.Current != test.samples[len(test.samples)-1].Values {
+				t.Fatalf("current %+v is not the last sample", rates.Current)
+			}
+		})
+	}
+}
+
+func closeTo(a float64, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
@@ -0,0 +1,43 @@
+package haproxy
+
+// the stats of every frontend, backend and server summed over the runtime apis, in the order they are first seen
+func (s HaproxyStats) Merged() []HaproxyNativeStat {
+	merged := []HaproxyNativeStat{}
+	positions := map[string]int{}
+	for _, collection := range s {
+		for _, stat := range collection.Stats {
+			key := stat.Type + "/" + stat.BackendName + "/" + stat.Name
+			position, exist := positions[key]
+			if !exist {
+				positions[key] = len(merged)
+				merged = append(merged, stat)
+				continue
+			}
+			merged[position].Stats.add(&stat.Stats)
+		}
+	}
+	return merged
+}
+
+// add the counters and gauges of another process, the status and maximums of the first one are kept
+func (v *HaproxyNativeStatValues) add(other *HaproxyNativeStatValues) {
+	v.Bin += other.Bin
+	v.Bout += other.Bout
+	v.Stot += other.Stot
+	v.Scur += other.Scur
+	v.Rate += other.Rate
+	v.ReqRate += other.ReqRate
+	v.ReqTotal += other.ReqTotal
+	v.Ereq += other.Ereq
+	v.Econ += other.Econ
+	v.Eresp += other.Eresp
+	v.Dreq += other.Dreq
+	v.Dresp += other.Dresp
+	v.Qcur += other.Qcur
+	v.Hrsp1Xx += other.Hrsp1Xx
+	v.Hrsp2Xx += other.Hrsp2Xx
+	v.Hrsp3Xx += other.Hrsp3Xx
+	v.Hrsp4Xx += other.Hrsp4Xx
+	v.Hrsp5Xx += other.Hrsp5Xx
+	v.HrspOther += other.HrspOther
+}
@@ -0,0 +1,221 @@
+package haproxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// a routing graph: binds -> frontends -> backends (through default_backend and switching rules) -> servers
+type HaproxyTopology struct {
+	Nodes []HaproxyTopologyNode `json:"nodes"`
+	Edges []HaproxyTopologyEdge `json:"edges"`
+}
+
+type HaproxyTopologyNode struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"` // ResourceBind, ResourceFrontend, ResourceBackend or ResourceServer
+	Name    string `json:"name"`
+	Status  string `json:"status,omitempty"`  // from the native stats when requested
+	Missing bool   `json:"missing,omitempty"` // a backend referenced but not defined
+}
+
+type HaproxyTopologyEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label,omitempty"` // "default" or the switching rule condition
+}
+
+// build the routing graph of the live configuration, withStats annotates the nodes with their status
+func (h *haproxyClient) Topology(withStats bool) (*HaproxyTopology, error) {
+	state, err := h.fetchState()
+	if err != nil {
+		return nil, err
+	}
+	var stats HaproxyStats
+	if withStats {
+		live, err := h.GetStats()
+		if err != nil {
+			return nil, err
+		}
+		stats = *live
+	}
+	return BuildTopology(state, stats), nil
+}
+
+// build the routing graph of a configuration, stats may be nil
+func BuildTopology(state *HaproxyDesiredState, stats HaproxyStats) *HaproxyTopology {
+	topology := HaproxyTopology{Nodes: []HaproxyTopologyNode{}, Edges: []HaproxyTopologyEdge{}}
+	ids := map[string]string{}
+	node := func(kind string, parent string, name string) string {
+		key := kind + "/" + parent + "/" + name
+		if id, exist := ids[key]; exist {
+			return id
+		}
+		id := fmt.Sprintf("n%d", len(topology.Nodes)+1)
+		ids[key] = id
+		n := HaproxyTopologyNode{ID: id, Kind: kind, Name: name}
+		if stats != nil && kind != ResourceBind {
+			statType, backend := kind, ""
+			if kind == ResourceServer {
+				backend = parent
+			}
+			n.Status = stats.sum(func(stat HaproxyNativeStat) bool {
+				return stat.Type == statType && stat.Name == name && stat.BackendName == backend
+			}).Status
+		}
+		topology.Nodes = append(topology.Nodes, n)
+		return id
+	}
+	edge := func(from string, to string, label string) {
+		topology.Edges = append(topology.Edges, HaproxyTopologyEdge{From: from, To: to, Label: label})
+	}
+
+	// backends first so that the missing ones are the only nodes created while walking the frontends
+	for _, b := range state.Backends {
+		backend := node(ResourceBackend, "", b.Backend.Name)
+		for _, server := range b.Servers {
+			label := server.Address
+			if server.Port != 0 {
+				label = fmt.Sprintf("%s:%d", server.Address, server.Port)
+			}
+			edge(backend, node(ResourceServer, b.Backend.Name, server.Name), label)
+		}
+	}
+	backendNode := func(name string) string {
+		if _, exist := ids[ResourceBackend+"//"+name]; !exist {
+			id := node(ResourceBackend, "", name)
+			topology.Nodes[len(topology.Nodes)-1].Missing = true
+			return id
+		}
+		return ids[ResourceBackend+"//"+name]
+	}
+	for _, f := range state.Frontends {
+		frontend := node(ResourceFrontend, "", f.Frontend.Name)
+		for _, bind := range f.Binds {
+			label := bind.Address
+			if bind.Port != 0 {
+				label = fmt.Sprintf("%s:%d", bind.Address, bind.Port)
+			}
+			edge(node(ResourceBind, f.Frontend.Name, bind.Name), frontend, label)
+		}
+		for _, rule := range f.BackendSwitchingRules {
+			edge(frontend, backendNode(rule.Name), strings.TrimSpace(rule.Cond+" "+rule.CondTest))
+		}
+		if f.Frontend.DefaultBackend != "" {
+			edge(frontend, backendNode(f.Frontend.DefaultBackend), "default")
+		}
+	}
+	return &topology
+}
+
+// render the graph in Graphviz DOT, eg: client.Topology(true) then `dot -Tsvg`
+func (t *HaproxyTopology) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph haproxy {\n\trankdir=LR;\n")
+	for _, n := range t.Nodes {
+		attributes := fmt.Sprintf("label=%q shape=%s", topologyLabel(n), topologyShape(n.Kind))
+		if color, exist := topologyColors[topologyClass(n.Status)]; exist {
+			attributes += fmt.Sprintf(" style=filled fillcolor=%q", color)
+		}
+		if n.Missing {
+			attributes += " style=dashed color=red"
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", n.ID, attributes)
+	}
+	for _, e := range t.Edges {
+		if e.Label == "" {
+			fmt.Fprintf(&b, "\t%s -> %s;\n", e.From, e.To)
+		} else {
+			fmt.Fprintf(&b, "\t%s -> %s [label=%q];\n", e.From, e.To, e.Label)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// render the graph as a Mermaid flowchart
+func (t *HaproxyTopology) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	classes := map[string][]string{}
+	for _, n := range t.Nodes {
+		label := mermaidEscape(topologyLabel(n))
+		switch n.Kind {
+		case ResourceFrontend:
+			fmt.Fprintf(&b, "\t%s[\"%s\"]\n", n.ID, label)
+		case ResourceBackend:
+			fmt.Fprintf(&b, "\t%s[[\"%s\"]]\n", n.ID, label)
+		default:
+			fmt.Fprintf(&b, "\t%s([\"%s\"])\n", n.ID, label)
+		}
+		if n.Missing {
+			classes["missing"] = append(classes["missing"], n.ID)
+		} else if class := topologyClass(n.Status); class != "" {
+			classes[class] = append(classes[class], n.ID)
+		}
+	}
+	for _, e := range t.Edges {
+		if e.Label == "" {
+			fmt.Fprintf(&b, "\t%s --> %s\n", e.From, e.To)
+		} else {
+			fmt.Fprintf(&b, "\t%s -->|\"%s\"| %s\n", e.From, mermaidEscape(e.Label), e.To)
+		}
+	}
+	for _, class := range []string{"up", "down", "maint", "missing"} {
+		if len(classes[class]) == 0 {
+			continue
+		}
+		style := "stroke:#ff0000,stroke-dasharray:4"
+		if class != "missing" {
+			style = "fill:" + topologyColors[class]
+		}
+		fmt.Fprintf(&b, "\tclassDef %s %s\n", class, style)
+		fmt.Fprintf(&b, "\tclass %s %s\n", strings.Join(classes[class], ","), class)
+	}
+	return b.String()
+}
+
+var topologyColors = map[string]string{
+	"up":    "#c8f7c5",
+	"down":  "#f7c5c5",
+	"maint": "#dddddd",
+}
+
+func topologyLabel(n HaproxyTopologyNode) string {
+	label := n.Kind + " " + n.Name
+	if n.Missing {
+		label += " (missing)"
+	}
+	if n.Status != "" {
+		label += " [" + n.Status + "]"
+	}
+	return label
+}
+
+func topologyShape(kind string) string {
+	switch kind {
+	case ResourceFrontend:
+		return "box"
+	case ResourceBackend:
+		return "box3d"
+	default:
+		return "ellipse"
+	}
+}
+
+func topologyClass(status string) string {
+	switch {
+	case status == "":
+		return ""
+	case IsServerUp(status), status == "OPEN":
+		return "up"
+	case strings.HasPrefix(status, "MAINT"), strings.HasPrefix(status, "DRAIN"):
+		return "maint"
+	default:
+		return "down"
+	}
+}
+
+func mermaidEscape(text string) string {
+	return strings.ReplaceAll(text, "\"", "#quot;")
+}
@@ -0,0 +1,136 @@
+package haproxy
+
+import (
+	"testing"
+)
+
+const topologyState = `
+backends:
+  - backend: {name: app}
+    servers:
+      - {name: app1, address: 10.0.0.1, port: 8080}
+      - {name: app2, address: 10.0.0.2}
+frontends:
+  - frontend: {name: www, default_backend: app}
+    binds: [{name: http, address: "*", port: 80}]
+    backend_switching_rules: [{name: legacy, cond: if, cond_test: "{ hdr(host) -m str \"old\" }"}]
+`
+
+func TestTopologyRender(t *testing.T) {
+	stats := HaproxyStats{{Stats: []HaproxyNativeStat{
+		{Type: "frontend", Name: "www", Stats: HaproxyNativeStatValues{Status: "OPEN"}},
+		{Type: "server", BackendName: "app", Name: "app1", Stats: HaproxyNativeStatValues{Status: "UP"}},
+		{Type: "server", BackendName: "app", Name: "app2", Stats: HaproxyNativeStatValues{Status: "MAINT"}},
+	}}}
+	tests := []struct {
+		name    string
+		stats   HaproxyStats
+		dot     string
+		mermaid string
+	}{
+		{
+			"without stats",
+			nil,
+			`digraph haproxy {
+	rankdir=LR;
+	n1 [label="backend app" shape=box3d];
+	n2 [label="server app1" shape=ellipse];
+	n3 [label="server app2" shape=ellipse];
+	n4 [label="frontend www" shape=box];
+	n5 [label="bind http" shape=ellipse];
+	n6 [label="backend legacy (missing)" shape=box3d style=dashed color=red];
+	n1 -> n2 [label="10.0.0.1:8080"];
+	n1 -> n3 [label="10.0.0.2"];
+	n5 -> n4 [label="*:80"];
+	n4 -> n6 [label="if { hdr(host) -m str \"old\" }"];
+	n4 -> n1 [label="default"];
+}
+`,
+			`flowchart LR
+	n1[["backend app"]]
+	n2(["server app1"])
+	n3(["server app2"])
+	n4["frontend www"]
+	n5(["bind http"])
+	n6[["backend legacy (missing)"]]
+	n1 -->|"10.0.0.1:8080"| n2
+	n1 -->|"10.0.0.2"| n3
+	n5 -->|"*:80"| n4
+	n4 -->|"if { hdr(host) -m str #quot;old#quot; }"| n6
+	n4 -->|"default"| n1
+	classDef missing stroke:#ff0000,stroke-dasharray:4
+	class n6 missing
+`,
+		},
+		{
+			"with stats",
+			stats,
+			`digraph haproxy {
+	rankdir=LR;
+	n1 [label="backend app" shape=box3d];
+	n2 [label="server app1 [UP]" shape=ellipse style=filled fillcolor="#c8f7c5"];
+	n3 [label="server app2 [MAINT]" shape=ellipse style=filled fillcolor="#dddddd"];
+	n4 [label="frontend www [OPEN]" shape=box style=filled fillcolor="#c8f7c5"];
+	n5 [label="bind http" shape=ellipse];
+	n6 [label="backend legacy (missing)" shape=box3d style=dashed color=red];
+	n1 -> n2 [label="10.0.0.1:8080"];
+	n1 -> n3 [label="10.0.0.2"];
+	n5 -> n4 [label="*:80"];
+	n4 -> n6 [label="if { hdr(host) -m str \"old\" }"];
+	n4 -> n1 [label="default"];
+}
+`,
+			`flowchart LR
+	n1[["backend app"]]
+	n2(["server app1 [UP]"])
+	n3(["server app2 [MAINT]"])
+	n4["frontend www [OPEN]"]
+	n5(["bind http"])
+	n6[["backend legacy (missing)"]]
+	n1 -->|"10.0.0.1:8080"| n2
+	n1 -->|"10.0.0.2"| n3
+	n5 -->|"*:80"| n4
+	n4 -->|"if { hdr(host) -m str #quot;old#quot; }"| n6
+	n4 -->|"default"| n1
+	classDef up fill:#c8f7c5
+	class n2,n4 up
+	classDef maint fill:#dddddd
+	class n3 maint
+	classDef missing stroke:#ff0000,stroke-dasharray:4
+	class n6 missing
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			topology := BuildTopology(parseState(t, topologyState), test.stats)
+			if dot := topology.DOT(); dot != test.dot {
+				t.Fatalf("DOT\n%s\nwant\n%s", dot, test.dot)
+			}
+			if mermaid := topology.Mermaid(); mermaid != test.mermaid {
+				t.Fatalf("Mermaid\n%s\nwant\n%s", mermaid, test.mermaid)
+			}
+		})
+	}
+}
+
+func TestTopologyClass(t *testing.T) {
+	tests := []struct {
+		status string
+		class  string
+	}{
+		{"", ""},
+		{"UP", "up"},
+		{"OPEN", "up"},
+		{"no check", "up"},
+		{"MAINT", "maint"},
+		{"DRAIN", "maint"},
+		{"DOWN", "down"},
+		{"NOLB", "down"},
+	}
+	for _, test := range tests {
+		if class := topologyClass(test.status); class != test.class {
+			t.Fatalf("class of %q is %q, want %q", test.status, class, test.class)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package haproxy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// the problems found by Validate, in field order
+type HaproxyValidationError struct {
+	Kind     string   `json:"kind"`
+	Name     string   `json:"name,omitempty"`
+	Problems []string `json:"problems"`
+}
+
+func (e *HaproxyValidationError) Error() string {
+	subject := e.Kind
+	if e.Name != "" {
+		subject += " " + e.Name
+	}
+	return fmt.Sprintf("invalid %s: %s", subject, strings.Join(e.Problems, "; "))
+}
+
+// collects the problems of a model
+type validator struct {
+	err HaproxyValidationError
+}
+
+func newValidator(kind string, name string) *validator {
+	return &validator{err: HaproxyValidationError{Kind: kind, Name: name}}
+}
+
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.err.Problems = append(v.err.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) name(field string, value string) {
+	v.check(value != "", "%s is required", field)
+	v.check(value == "" || objectNamePattern.MatchString(value), "%s %q may only contain letters, digits, '-', '_', '.' and ':'", field, value)
+}
+
+// 0 leaves the port unset
+func (v *validator) port(field string, port int) {
+	v.check(port >= 0 && port <= 65535, "%s %d out of range 0-65535", field, port)
+}
+
+func (v *validator) index(index int) {
+	v.check(index >= 0, "index %d is negative", index)
+}
+
+func (v *validator) condition(cond string, condTest string) {
+	if err := ValidateCondition(cond, condTest); err != nil {
+		v.check(false, "%v", err)
+	}
+}
+
+func (v *validator) result() error {
+	if len(v.err.Problems) == 0 {
+		return nil
+	}
+	return &v.err
+}
+
+// the characters haproxy accepts in section, server and acl names
+var objectNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+func oneOf[T ~string](values ...T) map[T]bool {
+	set := map[T]bool{}
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+func validValue[T ~string](set map[T]bool, value T) bool {
+	return value == "" || set[value]
+}
+
+var (
+	proxyModes          = oneOf(ModeHTTP, ModeTCP)
+	balanceAlgorithms   = oneOf(BalanceRoundRobin, BalanceStaticRR, BalanceLeastConn, BalanceFirst, BalanceSource, BalanceURI, BalanceURLParam, BalanceHdr, BalanceRandom, BalanceRdpCookie, BalanceHash)
+	serverChecks        = oneOf(CheckEnabled, CheckDisabled)
+	advChecks           = oneOf(AdvCheckHttpchk, AdvCheckSslHelloChk, AdvCheckSmtpchk, AdvCheckLdapCheck, AdvCheckMysqlCheck, AdvCheckPgsqlCheck, AdvCheckTcpCheck, AdvCheckRedisCheck)
+	httpConnectionModes = oneOf(HTTPConnectionClose, HTTPConnectionServerClose, HTTPConnectionKeepAlive)
+)
+
+// the rule types and actions of the dataplane api v2 spec. The lists are closed: an action added by
+// a later dataplane version is refused until it is added here.
+var (
+	httpRequestRuleTypes = oneOf[HttpRequestRuleType]("add-acl", "add-header", "allow", "auth", "cache-use", "capture",
+		"del-acl", "del-header", "del-map", "deny", "disable-l7-retry", "do-resolve", "early-hint", "lua", "normalize-uri",
+		"redirect", "reject", "replace-header", "replace-path", "replace-pathq", "replace-uri", "replace-value", "return",
+		"sc-add-gpc", "sc-inc-gpc", "sc-inc-gpc0", "sc-inc-gpc1", "sc-set-gpt", "sc-set-gpt0", "send-spoe-group",
+		"set-bandwidth-limit", "set-dst", "set-dst-port", "set-header", "set-log-level", "set-map", "set-mark", "set-method",
+		"set-nice", "set-path", "set-pathq", "set-priority-class", "set-priority-offset", "set-query", "set-src", "set-src-port",
+		"set-timeout", "set-tos", "set-uri", "set-var", "set-var-fmt", "silent-drop", "strict-mode", "tarpit", "track-sc0",
+		"track-sc1", "track-sc2", "unset-var", "use-service", "wait-for-body", "wait-for-handshake")
+	httpResponseRuleTypes = oneOf[HttpResponseRuleType]("add-acl", "add-header", "allow", "cache-store", "capture", "del-acl",
+		"del-header", "del-map", "deny", "lua", "redirect", "replace-header", "replace-value", "return", "sc-add-gpc",
+		"sc-inc-gpc", "sc-inc-gpc0", "sc-inc-gpc1", "sc-set-gpt", "sc-set-gpt0", "send-spoe-group", "set-bandwidth-limit",
+		"set-header", "set-log-level", "set-map", "set-mark", "set-nice", "set-status", "set-timeout", "set-tos", "set-var",
+		"set-var-fmt", "silent-drop", "strict-mode", "track-sc0", "track-sc1", "track-sc2", "unset-var", "wait-for-body")
+	tcpRequestRuleTypes   = oneOf(TcpRequestRuleConnection, TcpRequestRuleContent, TcpRequestRuleSession, TcpRequestRuleInspectDelay)
+	tcpResponseRuleTypes  = oneOf(TcpResponseRuleContent, TcpResponseRuleInspectDelay)
+	tcpRequestRuleActions = oneOf[TcpRuleAction]("accept", "attach-srv", "capture", "do-resolve", "expect-netscaler-cip",
+		"expect-proxy", "lua", "reject", "sc-add-gpc", "sc-inc-gpc", "sc-inc-gpc0", "sc-inc-gpc1", "sc-set-gpt", "sc-set-gpt0",
+		"send-spoe-group", "set-bandwidth-limit", "set-dst", "set-dst-port", "set-fc-mark", "set-fc-tos", "set-log-level",
+		"set-mark", "set-nice", "set-priority-class", "set-priority-offset", "set-src", "set-src-port", "set-tos", "set-var",
+		"set-var-fmt", "silent-drop", "switch-mode", "track-sc0", "track-sc1", "track-sc2", "unset-var", "use-service")
+	tcpResponseRuleActions = oneOf[TcpRuleAction]("accept", "close", "lua", "reject", "sc-add-gpc", "sc-inc-gpc", "sc-inc-gpc0",
+		"sc-inc-gpc1", "sc-set-gpt", "sc-set-gpt0", "send-spoe-group", "set-bandwidth-limit", "set-fc-mark", "set-fc-tos",
+		"set-log-level", "set-mark", "set-nice", "set-tos", "set-var", "set-var-fmt", "silent-drop", "unset-var")
+)
+
+func (m ProxyMode) Valid() bool            { return validValue(proxyModes, m) }
+func (a BalanceAlgorithm) Valid() bool     { return validValue(balanceAlgorithms, a) }
+func (c ServerCheck) Valid() bool          { return validValue(serverChecks, c) }
+func (c AdvCheck) Valid() bool             { return validValue(advChecks, c) }
+func (m HTTPConnectionMode) Valid() bool   { return validValue(httpConnectionModes, m) }
+func (t HttpRequestRuleType) Valid() bool  { return httpRequestRuleTypes[t] }
+func (t HttpResponseRuleType) Valid() bool { return httpResponseRuleTypes[t] }
+func (t TcpRequestRuleType) Valid() bool   { return tcpRequestRuleTypes[t] }
+func (t TcpResponseRuleType) Valid() bool  { return tcpResponseRuleTypes[t] }
+
+// valid in a tcp-request or a tcp-response rule, the rules check their own list
+func (a TcpRuleAction) Valid() bool {
+	return validValue(tcpRequestRuleActions, a) || validValue(tcpResponseRuleActions, a)
+}
+
+func (d *HaproxyAddNamedDefaults) Validate() error {
+	v := newValidator("defaults", d.Name)
+	v.name("name", d.Name)
+	v.check(d.Mode.Valid(), "invalid mode %q", d.Mode)
+	if d.Balance != nil {
+		v.check(d.Balance.Algorithm != "" && d.Balance.Algorithm.Valid(), "invalid balance algorithm %q", d.Balance.Algorithm)
+	}
+	v.check(d.HTTPConnectionMode.Valid(), "invalid http connection mode %q", d.HTTPConnectionMode)
+	v.check(d.Maxconn >= 0, "maxconn %d is negative", d.Maxconn)
+	for field, timeout := range map[string]int{"client_timeout": d.ClientTimeout, "connect_timeout": d.ConnectTimeout, "server_timeout": d.ServerTimeout, "queue_timeout": d.QueueTimeout, "http_keep_alive_timeout": d.HTTPKeepAliveTimeout} {
+		v.check(timeout >= 0, "%s %d is negative", field, timeout)
+	}
+	return v.result()
+}
+
+func (b *HaproxyAddBind) Validate() error {
+	v := newValidator("bind", b.Name)
+	v.name("name", b.Name)
+	v.check(b.Address != "" || b.Port != 0, "address or port is required")
+	v.port("port", b.Port)
+	v.check(b.SslCertificate == "" || b.Ssl, "ssl_certificate requires ssl")
+	v.check(b.Maxconn >= 0, "maxconn %d is negative", b.Maxconn)
+	return v.result()
+}
+
+func (b *HaproxyAddBackend) Validate() error {
+	v := newValidator("backend", b.Name)
+	v.name("name", b.Name)
+	v.check(b.Mode.Valid(), "invalid mode %q", b.Mode)
+	v.check(b.Balance.Algorithm.Valid(), "invalid balance algorithm %q", b.Balance.Algorithm)
+	v.check(b.AdvCheck.Valid(), "invalid adv_check %q", b.AdvCheck)
+	v.check(b.Forwardfor.Enabled == "" || b.Forwardfor.Enabled == "enabled", "invalid forwardfor %q", b.Forwardfor.Enabled)
+	v.check(b.AdvCheck == AdvCheckHttpchk || (b.HttpchkParams.Method == "" && b.HttpchkParams.URI == ""), "httpchk_params require adv_check httpchk")
+	return v.result()
+}
+
+func (f *HaproxyAddFrontend) Validate() error {
+	v := newValidator("frontend", f.Name)
+	v.name("name", f.Name)
+	v.check(f.Mode.Valid(), "invalid mode %q", f.Mode)
+	v.check(f.HTTPConnectionMode.Valid(), "invalid http connection mode %q", f.HTTPConnectionMode)
+	v.check(f.HTTPConnectionMode == "" || f.Mode != ModeTCP, "http connection mode %s requires mode http", f.HTTPConnectionMode)
+	v.check(f.Maxconn >= 0, "maxconn %d is negative", f.Maxconn)
+	return v.result()
+}
+
+func (a *HaproxyAddAcl) Validate() error {
+	v := newValidator("acl", a.AclName)
+	v.name("acl_name", a.AclName)
+	v.index(a.Index)
+	if err := validateFetch(a.Criterion); err != nil {
+		v.check(false, "criterion: %v", err)
+	}
+	return v.result()
+}
+
+func (s *HaproxyAddServer) Validate() error {
+	v := newValidator("server", s.Name)
+	v.name("name", s.Name)
+	v.check(s.Address != "", "address is required")
+	v.port("port", s.Port)
+	v.check(s.Check.Valid(), "invalid check %q", s.Check)
+	if s.Weight != nil {
+		v.check(*s.Weight >= 0 && *s.Weight <= MaxServerWeight, "weight %d out of range 0-%d", *s.Weight, MaxServerWeight)
+	}
+	return v.result()
+}
+
+func (r *HaproxyAddHttpRequestRule) Validate() error {
+	v := newValidator("http request rule", string(r.Type))
+	v.index(r.Index)
+	v.check(r.Type.Valid(), "invalid type %q", r.Type)
+	v.condition(r.Cond, r.CondTest)
+	switch r.Type {
+	case HttpRequestRuleAddHeader, HttpRequestRuleSetHeader:
+		v.check(r.HdrName != "" && r.HdrFormat != "", "%s requires hdr_name and hdr_format", r.Type)
+	case HttpRequestRuleDelHeader:
+		v.check(r.HdrName != "", "%s requires hdr_name", r.Type)
+	case HttpRequestRuleReplacePath:
+		v.check(r.PathMatch != "" && r.PathFmt != "", "%s requires path_match and path_fmt", r.Type)
+	case HttpRequestRuleSetPath:
+		v.check(r.PathFmt != "", "%s requires path_fmt", r.Type)
+	}
+	return v.result()
+}
+
+func (r *HaproxyAddHttpResponseRule) Validate() error {
+	v := newValidator("http response rule", string(r.Type))
+	v.index(r.Index)
+	v.check(r.Type.Valid(), "invalid type %q", r.Type)
+	v.condition(r.Cond, r.CondTest)
+	switch r.Type {
+	case HttpResponseRuleAddHeader, HttpResponseRuleSetHeader:
+		v.check(r.HdrName != "" && r.HdrFormat != "", "%s requires hdr_name and hdr_format", r.Type)
+	case HttpResponseRuleDelHeader:
+		v.check(r.HdrName != "", "%s requires hdr_name", r.Type)
+	case HttpResponseRuleReplaceHeader:
+		v.check(r.HdrName != "" && r.HdrMatch != "" && r.HdrFormat != "", "%s requires hdr_name, hdr_match and hdr_format", r.Type)
+	case HttpResponseRuleSetStatus:
+		v.check(r.Status >= 100 && r.Status <= 999, "status %d out of range 100-999", r.Status)
+	case HttpResponseRuleRedirect:
+		v.check(r.RedirType == "location" || r.RedirType == "prefix" || r.RedirType == "scheme", "invalid redir_type %q, expected location, prefix or scheme", r.RedirType)
+		v.check(r.RedirValue != "", "%s requires redir_value", r.Type)
+		v.check(r.RedirCode == 0 || r.RedirCode == 301 || r.RedirCode == 302 || r.RedirCode == 303 || r.RedirCode == 307 || r.RedirCode == 308, "invalid redir_code %d", r.RedirCode)
+	case HttpResponseRuleDeny:
+		v.check(r.DenyStatus == 0 || (r.DenyStatus >= 200 && r.DenyStatus <= 599), "deny_status %d out of range 200-599", r.DenyStatus)
+	case HttpResponseRuleReturn:
+		v.check(r.ReturnStatusCode == 0 || (r.ReturnStatusCode >= 200 && r.ReturnStatusCode <= 599), "return_status_code %d out of range 200-599", r.ReturnStatusCode)
+	}
+	return v.result()
+}
+
+func (r *HaproxyAddTcpRequestRule) Validate() error {
+	v := newValidator("tcp request rule", string(r.Type))
+	v.index(r.Index)
+	v.check(r.Type.Valid(), "invalid type %q", r.Type)
+	v.check(validValue(tcpRequestRuleActions, r.Action), "invalid action %q", r.Action)
+	v.condition(r.Cond, r.CondTest)
+	if r.Type == TcpRequestRuleInspectDelay {
+		v.check(r.Timeout > 0, "%s requires a timeout", r.Type)
+	} else if r.Type != "" {
+		v.check(r.Action != "", "%s requires an action", r.Type)
+	}
+	if r.Action == TcpRuleActionSetVar {
+		v.check(r.VarName != "" && r.VarScope != "" && r.Expr != "", "%s requires var_scope, var_name and expr", r.Action)
+	}
+	return v.result()
+}
+
+func (r *HaproxyAddTcpResponseRule) Validate() error {
+	v := newValidator("tcp response rule", string(r.Type))
+	v.index(r.Index)
+	v.check(r.Type.Valid(), "invalid type %q", r.Type)
+	v.check(validValue(tcpResponseRuleActions, r.Action), "invalid action %q", r.Action)
+	v.condition(r.Cond, r.CondTest)
+	if r.Type == TcpResponseRuleInspectDelay {
+		v.check(r.Timeout > 0, "%s requires a timeout", r.Type)
+	} else if r.Type != "" {
+		v.check(r.Action != "", "%s requires an action", r.Type)
+	}
+	return v.result()
+}
+
+func (r *HaproxyAddBackendSwitchingRule) Validate() error {
+	v := newValidator("backend switching rule", r.Name)
+	v.index(r.Index)
+	v.check(r.Name != "", "name is required")
+	// the backend may be computed at runtime, eg: %[req.hdr(host),lower]
+	v.check(r.Name == "" || strings.Contains(r.Name, "%[") || objectNamePattern.MatchString(r.Name), "invalid backend name %q", r.Name)
+	v.condition(r.Cond, r.CondTest)
+	return v.result()
+}
+
+func (r *HaproxyAddServerSwitchingRule) Validate() error {
+	v := newValidator("server switching rule", r.TargetServer)
+	v.index(r.Index)
+	v.name("target_server", r.TargetServer)
+	v.condition(r.Cond, r.CondTest)
+	return v.result()
+}
@@ -0,0 +1,60 @@
+package haproxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	weight := MaxServerWeight + 1
+	tests := []struct {
+		name    string
+		model   interface{ Validate() error }
+		problem string // a substring of the error, empty when the model is valid
+	}{
+		{"backend", &HaproxyAddBackend{Name: "app", Mode: ModeHTTP}, ""},
+		{"bad mode", &HaproxyAddBackend{Name: "app", Mode: "udp"}, `invalid mode "udp"`},
+		{"bad balance algorithm", &HaproxyAddBackend{Name: "app", Balance: HaproxyBalance{Algorithm: "fastest"}}, `invalid balance algorithm "fastest"`},
+		{"bad server check", &HaproxyAddServer{Name: "app1", Address: "10.0.0.1", Check: "on"}, `invalid check "on"`},
+		{"missing backend name", &HaproxyAddBackend{Mode: ModeHTTP}, "name is required"},
+		{"missing server name", &HaproxyAddServer{Address: "10.0.0.1"}, "name is required"},
+		{"missing acl name", &HaproxyAddAcl{Criterion: "path_beg", Value: "/api"}, "acl_name is required"},
+		{"bad name", &HaproxyAddFrontend{Name: "www frontend"}, `name "www frontend" may only contain`},
+		{"port out of range", &HaproxyAddServer{Name: "app1", Address: "10.0.0.1", Port: 65536}, "port 65536 out of range 0-65535"},
+		{"negative bind port", &HaproxyAddBind{Name: "http", Address: "*", Port: -1}, "port -1 out of range 0-65535"},
+		{"weight out of range", &HaproxyAddServer{Name: "app1", Address: "10.0.0.1", Weight: &weight}, "weight 257 out of range 0-256"},
+		{"bad cond_test", &HaproxyAddBackendSwitchingRule{Name: "api", Cond: CondIf, CondTest: "is_api ||"}, "missing term at the end"},
+		{"cond_test without cond", &HaproxyAddHttpRequestRule{Type: HttpRequestRuleDeny, CondTest: "is_admin"}, "cond"},
+		{"misspelled http request type", &HaproxyAddHttpRequestRule{Type: "redirct"}, `invalid type "redirct"`},
+		{"http request type of the spec", &HaproxyAddHttpRequestRule{Type: "set-var"}, ""},
+		{"misspelled http response type", &HaproxyAddHttpResponseRule{Type: "set-statuss"}, `invalid type "set-statuss"`},
+		{"tcp request type", &HaproxyAddTcpRequestRule{Type: "handshake", Action: TcpRuleActionAccept}, `invalid type "handshake"`},
+		{"tcp response action of a request rule", &HaproxyAddTcpResponseRule{Type: TcpResponseRuleContent, Action: "expect-proxy"}, `invalid action "expect-proxy"`},
+		{"tcp request action", &HaproxyAddTcpRequestRule{Type: TcpRequestRuleConnection, Action: "expect-proxy"}, ""},
+		{"http response add-header", &HaproxyAddHttpResponseRule{Type: HttpResponseRuleAddHeader, HdrName: "X-Frame-Options"}, "add-header requires hdr_name and hdr_format"},
+		{"http response set-header", &HaproxyAddHttpResponseRule{Type: HttpResponseRuleSetHeader, HdrFormat: "DENY"}, "set-header requires hdr_name and hdr_format"},
+		{"http response del-header", &HaproxyAddHttpResponseRule{Type: HttpResponseRuleDelHeader}, "del-header requires hdr_name"},
+		{"http response replace-header", &HaproxyAddHttpResponseRule{Type: HttpResponseRuleReplaceHeader, HdrName: "Set-Cookie", HdrFormat: `\1`}, "replace-header requires hdr_name, hdr_match and hdr_format"},
+		{"http response set-status", &HaproxyAddHttpResponseRule{Type: HttpResponseRuleSetStatus, Status: 42}, "status 42 out of range 100-999"},
+		{"http response redirect type", &HaproxyAddHttpResponseRule{Type: HttpResponseRuleRedirect, RedirType: "url", RedirValue: "/"}, `invalid redir_type "url"`},
+		{"http response redirect value", &HaproxyAddHttpResponseRule{Type: HttpResponseRuleRedirect, RedirType: "location"}, "redirect requires redir_value"},
+		{"http response redirect code", &HaproxyAddHttpResponseRule{Type: HttpResponseRuleRedirect, RedirType: "location", RedirValue: "/", RedirCode: 304}, "invalid redir_code 304"},
+		{"http response deny", &HaproxyAddHttpResponseRule{Type: HttpResponseRuleDeny, DenyStatus: 600}, "deny_status 600 out of range 200-599"},
+		{"http response return", &HaproxyAddHttpResponseRule{Type: HttpResponseRuleReturn, ReturnStatusCode: 100}, "return_status_code 100 out of range 200-599"},
+		{"http response allow", &HaproxyAddHttpResponseRule{Type: HttpResponseRuleAllow}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.model.Validate()
+			if test.problem == "" {
+				if err != nil {
+					t.Fatalf("error %v for a valid model", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.problem) {
+				t.Fatalf("error %v, want %q", err, test.problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package haproxy
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// the criterion of the host acls managed by the virtual hosts
+const VirtualHostCriterion = "hdr(host)"
+
+// a domain routed to a backend of servers: a hdr(host) acl on the frontend, a backend switching rule
+// using it and the backend with its servers.
+type HaproxyVirtualHost struct {
+	Host    string             `json:"host"`
+	Aliases []string           `json:"aliases,omitempty"` // other domains matched by the same acl
+	Backend string             `json:"backend,omitempty"` // derived from the host when empty, eg: vhost_app_example_com
+	Balance BalanceAlgorithm   `json:"balance,omitempty"` // the algorithm of a new backend, roundrobin when empty
+	Servers []HaproxyAddServer `json:"servers"`
+	Acl     string             `json:"acl,omitempty"` // the acl name, derived from the host when empty, eg: host_app_example_com
+}
+
+// manages the virtual hosts of a frontend
+type HaproxyVirtualHosts struct {
+	Client   IHaproxyClient
+	Frontend string
+}
+
+func NewHaproxyVirtualHosts(client IHaproxyClient, frontend string) *HaproxyVirtualHosts {
+	return &HaproxyVirtualHosts{Client: client, Frontend: frontend}
+}
+
+func (v *HaproxyVirtualHost) backendName() string {
+	if v.Backend != "" {
+		return v.Backend
+	}
+	return "vhost_" + hostIdentifier(v.Host)
+}
+
+func (v *HaproxyVirtualHost) aclName() string {
+	if v.Acl != "" {
+		return v.Acl
+	}
+	return "host_" + hostIdentifier(v.Host)
+}
+
+func (v *HaproxyVirtualHost) aclValue() string {
+	hosts := []string{strings.ToLower(v.Host)}
+	for _, alias := range v.Aliases {
+		hosts = append(hosts, strings.ToLower(alias))
+	}
+	return "-i " + strings.Join(hosts, " ")
+}
+
+func hostIdentifier(host string) string {
+	return strings.NewReplacer(".", "_", "-", "_", "*", "wildcard", ":", "_").Replace(strings.ToLower(host))
+}
+
+// the virtual hosts of the frontend: every hdr(host) acl used alone by an "if" backend switching rule,
+// the hosts of the acls sharing a name are merged in one virtual host
+func (m *HaproxyVirtualHosts) List() ([]HaproxyVirtualHost, error) {
+	acls, err := m.Client.GetAcls("frontend", m.Frontend)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := m.Client.GetBackendSwitchingRules(m.Frontend)
+	if err != nil {
+		return nil, err
+	}
+	backends := map[string]string{}
+	for _, rule := range rules.Data {
+		if rule.Cond == CondIf {
+			if _, exist := backends[strings.TrimSpace(rule.CondTest)]; !exist {
+				backends[strings.TrimSpace(rule.CondTest)] = rule.Name
+			}
+		}
+	}
+	vhosts := []HaproxyVirtualHost{}
+	positions := map[string]int{} // acl name -> position in vhosts
+	for _, acl := range acls.Data {
+		backend, routed := backends[acl.AclName]
+		if acl.Criterion != VirtualHostCriterion || !routed {
+			continue
+		}
+		hosts := []string{}
+		for _, field := range strings.Fields(acl.Value) {
+			if !strings.HasPrefix(field, "-") {
+				hosts = append(hosts, field)
+			}
+		}
+		if len(hosts) == 0 {
+			continue
+		}
+		if position, exist := positions[acl.AclName]; exist {
+			vhosts[position].Aliases = append(vhosts[position].Aliases, hosts...)
+			continue
+		}
+		servers, err := m.Client.GetServers(backend)
+		if err != nil {
+			return nil, err
+		}
+		positions[acl.AclName] = len(vhosts)
+		vhosts = append(vhosts, HaproxyVirtualHost{Host: hosts[0], Aliases: hosts[1:], Backend: backend, Acl: acl.AclName, Servers: servers.Data})
+	}
+	return vhosts, nil
+}
+
+// the virtual host matching the host (or one of its aliases), nil when there is none
+func (m *HaproxyVirtualHosts) Get(host string) (*HaproxyVirtualHost, error) {
+	vhosts, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range vhosts {
+		for _, name := range append([]string{vhosts[i].Host}, vhosts[i].Aliases...) {
+			if strings.EqualFold(name, host) {
+				return &vhosts[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// create or update the virtual host in one transaction: the backend is created when missing and
+// its servers are made to match, the acl and the switching rule are added or fixed.
+func (m *HaproxyVirtualHosts) Apply(vhost HaproxyVirtualHost) error {
+	if vhost.Host == "" {
+		return fmt.Errorf("virtual host without host")
+	}
+	backendName, aclName := vhost.backendName(), vhost.aclName()
+	backends, err := m.Client.GetBackends()
+	if err != nil {
+		return err
+	}
+	backendExist := false
+	for _, backend := range backends.Data {
+		backendExist = backendExist || backend.Name == backendName
+	}
+	current := []HaproxyAddServer{}
+	if backendExist {
+		servers, err := m.Client.GetServers(backendName)
+		if err != nil {
+			return err
+		}
+		current = servers.Data
+	}
+	acls, err := m.Client.GetAcls("frontend", m.Frontend)
+	if err != nil {
+		return err
+	}
+	rules, err := m.Client.GetBackendSwitchingRules(m.Frontend)
+	if err != nil {
+		return err
+	}
+
+	return RunTransaction(m.Client, func(client IHaproxyClient, transactionId string) error {
+		if !backendExist {
+			backend := HaproxyAddBackend{Name: backendName, Mode: ModeHTTP}
+			backend.Balance.Algorithm = vhost.Balance
+			if backend.Balance.Algorithm == "" {
+				backend.Balance.Algorithm = BalanceRoundRobin
+			}
+			if err := client.AddBackend(transactionId, &backend); err != nil {
+				return err
+			}
+		}
+		if err := syncServers(client, transactionId, backendName, current, vhost.Servers); err != nil {
+			return err
+		}
+
+		// the acls sharing the name are replaced by a single one
+		acl := HaproxyAddAcl{AclName: aclName, Criterion: VirtualHostCriterion, Value: vhost.aclValue(), Index: len(acls.Data)}
+		existing := []HaproxyAddAcl{}
+		for _, current := range acls.Data {
+			if current.AclName == aclName {
+				existing = append(existing, current)
+			}
+		}
+		switch {
+		case len(existing) == 0:
+			err = client.AddAcl("frontend", m.Frontend, transactionId, &acl)
+		case len(existing) > 1 || existing[0].Criterion != acl.Criterion || existing[0].Value != acl.Value:
+			acl.Index = existing[0].Index
+			err = client.ReplaceAcl("frontend", m.Frontend, existing[0].Index, transactionId, &acl)
+		}
+		if err != nil {
+			return err
+		}
+		// deleting from the highest index keeps the lower ones valid
+		for i := len(existing) - 1; i > 0; i-- {
+			if err := client.DeleteAcl("frontend", m.Frontend, existing[i].Index, transactionId); err != nil {
+				return err
+			}
+		}
+
+		rule := HaproxyAddBackendSwitchingRule{Cond: CondIf, CondTest: aclName, Name: backendName, Index: len(rules.Data)}
+		for _, current := range rules.Data {
+			if current.Cond == CondIf && strings.TrimSpace(current.CondTest) == aclName {
+				if current.Name == backendName {
+					return nil
+				}
+				rule.Index = current.Index
+				return client.ReplaceBackendSwitchingRule(m.Frontend, current.Index, transactionId, &rule)
+			}
+		}
+		return client.AddBackendSwitchingRule(m.Frontend, transactionId, &rule)
+	})
+}
+
+// remove the switching rules and the acl of the virtual host, and its backend unless the
+// backend is still used by another rule or a frontend default_backend
+func (m *HaproxyVirtualHosts) Remove(host string) error {
+	vhost, err := m.Get(host)
+	if err != nil {
+		return err
+	}
+	if vhost == nil {
+		return fmt.Errorf("virtual host %s not found in frontend %s", host, m.Frontend)
+	}
+	acls, err := m.Client.GetAcls("frontend", m.Frontend)
+	if err != nil {
+		return err
+	}
+	rules, err := m.Client.GetBackendSwitchingRules(m.Frontend)
+	if err != nil {
+		return err
+	}
+	frontends, err := m.Client.GetFrontends()
+	if err != nil {
+		return err
+	}
+
+	ruleIndexes, aclIndexes := []int{}, []int{}
+	for _, rule := range rules.Data {
+		if rule.Cond == CondIf && strings.TrimSpace(rule.CondTest) == vhost.Acl {
+			ruleIndexes = append(ruleIndexes, rule.Index)
+		}
+	}
+	for _, acl := range acls.Data {
+		if acl.AclName == vhost.Acl {
+			aclIndexes = append(aclIndexes, acl.Index)
+		}
+	}
+	// the backend is kept when referenced by anything but the removed rules
+	referenced := false
+	for _, frontend := range frontends.Data {
+		if frontend.DefaultBackend == vhost.Backend {
+			referenced = true
+			break
+		}
+		frontendRules := rules
+		if frontend.Name != m.Frontend {
+			if frontendRules, err = m.Client.GetBackendSwitchingRules(frontend.Name); err != nil {
+				return err
+			}
+		}
+		for _, rule := range frontendRules.Data {
+			removed := frontend.Name == m.Frontend && rule.Cond == CondIf && strings.TrimSpace(rule.CondTest) == vhost.Acl
+			if rule.Name == vhost.Backend && !removed {
+				referenced = true
+			}
+		}
+		if referenced {
+			break
+		}
+	}
+
+	// deleting from the highest index keeps the lower ones valid
+	sort.Sort(sort.Reverse(sort.IntSlice(ruleIndexes)))
+	sort.Sort(sort.Reverse(sort.IntSlice(aclIndexes)))
+	return RunTransaction(m.Client, func(client IHaproxyClient, transactionId string) error {
+		for _, index := range ruleIndexes {
+			if err := client.DeleteBackendSwitchingRule(m.Frontend, index, transactionId); err != nil {
+				return err
+			}
+		}
+		for _, index := range aclIndexes {
+			if err := client.DeleteAcl("frontend", m.Frontend, index, transactionId); err != nil {
+				return err
+			}
+		}
+		if referenced {
+			return nil
+		}
+		return client.DeleteBackend(vhost.Backend, transactionId)
+	})
+}
+
+// add, replace and delete the servers of a backend so they match the desired ones, the servers
+// replaced keep the live fields HaproxyAddServer has no field for
+func syncServers(client IHaproxyClient, transactionId string, backend string, current []HaproxyAddServer, desired []HaproxyAddServer) error {
+	existing := map[string]HaproxyAddServer{}
+	for _, server := range current {
+		existing[server.Name] = server
+	}
+	wanted := map[string]bool{}
+	for i := range desired {
+		server := desired[i]
+		wanted[server.Name] = true
+		live, exist := existing[server.Name]
+		if !exist {
+			if err := client.AddServer(backend, transactionId, &server); err != nil {
+				return err
+			}
+			continue
+		}
+		server.keep(&live.unmodeledFields)
+		if reflect.DeepEqual(serverDefaults(live), serverDefaults(server)) {
+			continue
+		}
+		if err := client.ReplaceServer(backend, server.Name, transactionId, &server); err != nil {
+			return err
+		}
+	}
+	for _, server := range current {
+		if !wanted[server.Name] {
+			if err := client.DeleteServer(backend, server.Name, transactionId); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// the server with the haproxy defaults made explicit, so that an unset weight or check compares
+// equal to the default one
+func serverDefaults(server HaproxyAddServer) HaproxyAddServer {
+	if server.Weight == nil {
+		weight := 1
+		server.Weight = &weight
+	}
+	if server.Check == "" {
+		server.Check = CheckDisabled
+	}
+	return server
+}
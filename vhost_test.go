@@ -0,0 +1,189 @@
+package haproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// a node recording the server changes and serving the frontend acls, rules and servers
+type fakeVhostNode struct {
+	IHaproxyClient
+	acls    []HaproxyAddAcl
+	rules   []HaproxyAddBackendSwitchingRule
+	servers map[string][]HaproxyAddServer
+	changes []string
+}
+
+func (n *fakeVhostNode) GetAcls(parentType string, parentName string) (*HaproxyAcls, error) {
+	return &HaproxyAcls{Data: n.acls}, nil
+}
+
+func (n *fakeVhostNode) GetBackendSwitchingRules(frontend string) (*HaproxyBackendSwitchingRules, error) {
+	return &HaproxyBackendSwitchingRules{Data: n.rules}, nil
+}
+
+func (n *fakeVhostNode) GetServers(backend string) (*HaproxyServers, error) {
+	return &HaproxyServers{Data: n.servers[backend]}, nil
+}
+
+func (n *fakeVhostNode) GetBackends() (*HaproxyBackends, error) {
+	backends := HaproxyBackends{}
+	if err := json.Unmarshal([]byte(`{"data":[{"name":"vhost_app"}]}`), &backends); err != nil {
+		return nil, err
+	}
+	return &backends, nil
+}
+
+func (n *fakeVhostNode) ReplaceAcl(parentType string, parentName string, index int, transactionId string, acl *HaproxyAddAcl) error {
+	n.changes = append(n.changes, fmt.Sprintf("replace acl %d %s", index, acl.Value))
+	return nil
+}
+
+func (n *fakeVhostNode) DeleteAcl(parentType string, parentName string, index int, transactionId string) error {
+	n.changes = append(n.changes, fmt.Sprintf("delete acl %d", index))
+	return nil
+}
+
+func (n *fakeVhostNode) GetConfigurationVersion() (*int, error) {
+	version := 1
+	return &version, nil
+}
+
+func (n *fakeVhostNode) StartTransaction(haproxyVersion string) (*string, error) {
+	transactionId := "tx"
+	return &transactionId, nil
+}
+
+func (n *fakeVhostNode) CommitTransactionReload(transactionId string) (string, error) {
+	return "", nil
+}
+
+func (n *fakeVhostNode) AddServer(backend string, transactionId string, server *HaproxyAddServer) error {
+	n.changes = append(n.changes, "add "+server.Name)
+	return nil
+}
+
+func (n *fakeVhostNode) ReplaceServer(backend string, name string, transactionId string, server *HaproxyAddServer) error {
+	n.changes = append(n.changes, "replace "+name)
+	return nil
+}
+
+func (n *fakeVhostNode) DeleteServer(backend string, name string, transactionId string) error {
+	n.changes = append(n.changes, "delete "+name)
+	return nil
+}
+
+func TestSyncServers(t *testing.T) {
+	one, two := 1, 2
+	tests := []struct {
+		name    string
+		current []HaproxyAddServer
+		desired []HaproxyAddServer
+		changes []string
+	}{
+		{
+			"new backend",
+			nil,
+			[]HaproxyAddServer{{Name: "a", Address: "10.0.0.1", Port: 80}, {Name: "b", Address: "10.0.0.2", Port: 80}},
+			[]string{"add a", "add b"},
+		},
+		{
+			"unchanged with the defaults made explicit",
+			[]HaproxyAddServer{{Name: "a", Address: "10.0.0.1", Port: 80, Weight: &one, Check: "disabled"}},
+			[]HaproxyAddServer{{Name: "a", Address: "10.0.0.1", Port: 80}},
+			[]string{},
+		},
+		{
+			"changed weight",
+			[]HaproxyAddServer{{Name: "a", Address: "10.0.0.1", Port: 80, Weight: &one}},
+			[]HaproxyAddServer{{Name: "a", Address: "10.0.0.1", Port: 80, Weight: &two}},
+			[]string{"replace a"},
+		},
+		{
+			"replaced and removed",
+			[]HaproxyAddServer{{Name: "a", Address: "10.0.0.1", Port: 80}, {Name: "b", Address: "10.0.0.2", Port: 80}},
+			[]HaproxyAddServer{{Name: "a", Address: "10.0.0.3", Port: 80}, {Name: "c", Address: "10.0.0.4", Port: 80}},
+			[]string{"replace a", "add c", "delete b"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := &fakeVhostNode{changes: []string{}}
+			if err := syncServers(node, "tx", "app", test.current, test.desired); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(node.changes, test.changes) {
+				t.Fatalf("changes %q, want %q", node.changes, test.changes)
+			}
+		})
+	}
+}
+
+func TestVirtualHostsList(t *testing.T) {
+	node := &fakeVhostNode{
+		acls: []HaproxyAddAcl{
+			{AclName: "host_app", Criterion: "hdr(host)", Value: "-i app.example.com www.app.example.com"},
+			{AclName: "host_api", Criterion: "hdr(host)", Value: "-i api.example.com", Index: 1},
+			{AclName: "is_admin", Criterion: "path_beg", Value: "/admin", Index: 2},
+			{AclName: "host_unrouted", Criterion: "hdr(host)", Value: "-i old.example.com", Index: 3},
+			{AclName: "host_app", Criterion: "hdr(host)", Value: "-i app.example.org", Index: 4},
+		},
+		rules: []HaproxyAddBackendSwitchingRule{
+			{Name: "vhost_app", Cond: "if", CondTest: "host_app"},
+			{Name: "vhost_api", Cond: "if", CondTest: " host_api ", Index: 1},
+			{Name: "admin", Cond: "if", CondTest: "is_admin", Index: 2},
+			{Name: "vhost_api_v2", Cond: "if", CondTest: "host_api", Index: 3},
+		},
+		servers: map[string][]HaproxyAddServer{
+			"vhost_app": {{Name: "app1", Address: "10.0.0.1", Port: 8080}},
+		},
+	}
+	vhosts, err := NewHaproxyVirtualHosts(node, "www").List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HaproxyVirtualHost{
+		{Host: "app.example.com", Aliases: []string{"www.app.example.com", "app.example.org"}, Backend: "vhost_app", Acl: "host_app", Servers: []HaproxyAddServer{{Name: "app1", Address: "10.0.0.1", Port: 8080}}},
+		{Host: "api.example.com", Aliases: []string{}, Backend: "vhost_api", Acl: "host_api"},
+	}
+	if !reflect.DeepEqual(vhosts, want) {
+		t.Fatalf("virtual hosts %+v, want %+v", vhosts, want)
+	}
+}
+
+func TestVirtualHostAclValue(t *testing.T) {
+	vhost := HaproxyVirtualHost{Host: "App.Example.com", Aliases: []string{"WWW.App.Example.com", "app.example.org"}}
+	if value := vhost.aclValue(); value != "-i app.example.com www.app.example.com app.example.org" {
+		t.Fatalf("acl value %q", value)
+	}
+}
+
+func TestVirtualHostsApplyMergesAcls(t *testing.T) {
+	node := &fakeVhostNode{
+		acls: []HaproxyAddAcl{
+			{AclName: "host_app", Criterion: "hdr(host)", Value: "-i app.example.com"},
+			{AclName: "is_admin", Criterion: "path_beg", Value: "/admin", Index: 1},
+			{AclName: "host_app", Criterion: "hdr(host)", Value: "-i app.example.org", Index: 2},
+			{AclName: "host_app", Criterion: "hdr(host)", Value: "-i app.example.net", Index: 3},
+		},
+		rules:   []HaproxyAddBackendSwitchingRule{{Name: "vhost_app", Cond: "if", CondTest: "host_app"}},
+		servers: map[string][]HaproxyAddServer{"vhost_app": {{Name: "app1", Address: "10.0.0.1", Port: 8080}}},
+		changes: []string{},
+	}
+	vhost := HaproxyVirtualHost{
+		Host:    "app.example.com",
+		Aliases: []string{"app.example.org", "app.example.net"},
+		Backend: "vhost_app",
+		Acl:     "host_app",
+		Servers: []HaproxyAddServer{{Name: "app1", Address: "10.0.0.1", Port: 8080}},
+	}
+	if err := NewHaproxyVirtualHosts(node, "www").Apply(vhost); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"replace acl 0 -i app.example.com app.example.org app.example.net", "delete acl 3", "delete acl 2"}
+	if !reflect.DeepEqual(node.changes, want) {
+		t.Fatalf("changes %q, want %q", node.changes, want)
+	}
+}